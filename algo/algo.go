@@ -0,0 +1,616 @@
+// Package algo works large orders with client-side execution algorithms, which slice a parent
+// order into child orders placed with goftx.Orders and follow them on the orders and fills channels.
+package algo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx"
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	defaultInterval = time.Second * 10
+	maxChildErrors  = 5
+	progressBuffer  = 16
+)
+
+var defaultClientIDs = goftx.NewClientIDSequence()
+
+type Status string
+
+const (
+	Pending   = Status("pending")
+	Running   = Status("running")
+	Paused    = Status("paused")
+	Cancelled = Status("cancelled")
+	Completed = Status("completed")
+	Failed    = Status("failed")
+)
+
+// Params describe the parent order.
+type Params struct {
+	Market string
+	Side   models.Side
+	Size   decimal.Decimal
+	// LimitPrice caps the price of children: buys never pay more, sells never get less.
+	// Children are limit orders at LimitPrice which rest if the market moved away, market orders without it.
+	// Market children are never resized, the rest of a slice is placed once the working child closed.
+	LimitPrice decimal.Decimal
+	// ClientTag tags the client IDs of children, so they could be found with OrderManager.OpenOrdersByClientTag
+	// and their fills attributed with OrderManager.AttributeFill.
+	ClientTag string
+}
+
+// Progress is a snapshot of the execution of an algo.
+type Progress struct {
+	Status       Status
+	Size         decimal.Decimal
+	FilledSize   decimal.Decimal
+	AvgFillPrice decimal.Decimal
+	// Target is the size the schedule wants filled by now, Size for algos without a schedule.
+	Target   decimal.Decimal
+	Children int
+	// Err is the last error of placing children, the algo fails after several ones in a row.
+	Err       error
+	UpdatedAt time.Time
+}
+
+func (p Progress) Remaining() decimal.Decimal {
+	return p.Size.Sub(p.FilledSize)
+}
+
+type Option func(a *Algo)
+
+// WithInterval sets how often the schedule is checked, it must be positive.
+func WithInterval(interval time.Duration) Option {
+	return func(a *Algo) {
+		a.interval = interval
+	}
+}
+
+// WithClientIDGenerator sets the generator of the client IDs of children, a generator shared by all algos by default.
+func WithClientIDGenerator(generator goftx.ClientIDGenerator) Option {
+	return func(a *Algo) {
+		a.clientIDs = generator
+	}
+}
+
+// WithMetadata floors child sizes to the size increment of the market,
+// an algo whose remaining size is below the increment is completed.
+func WithMetadata(metadata *goftx.MetadataCache) Option {
+	return func(a *Algo) {
+		a.metadata = metadata
+	}
+}
+
+// Algo executes a parent order by child orders, see NewTWAP, NewVWAP and NewIceberg.
+type Algo struct {
+	client   *goftx.Client
+	params   Params
+	interval time.Duration
+	metadata *goftx.MetadataCache
+	// target returns the cumulative size to be filled after elapsed, nil for algos that refill a displayed size.
+	target      func(elapsed time.Duration) decimal.Decimal
+	displaySize decimal.Decimal
+	prepare     func() error
+	clientIDs   goftx.ClientIDGenerator
+	lookback    int
+	resolution  models.Resolution
+
+	// ops serializes the REST requests of step, Pause and Cancel, which are made without holding mu.
+	ops         sync.Mutex
+	mu          sync.Mutex
+	status      Status
+	startedAt   time.Time
+	pausedAt    time.Time
+	paused      time.Duration
+	children    map[int64]*child
+	working     *child
+	fills       map[int64]bool
+	fillSize    decimal.Decimal
+	notional    decimal.Decimal
+	errs        int
+	lastErr     error
+	watchers    map[int]chan *Progress
+	nextWatcher int
+	wakeC       chan struct{}
+	doneC       chan struct{}
+	cancel      context.CancelFunc
+}
+
+type child struct {
+	id        int64
+	size      decimal.Decimal
+	filled    decimal.Decimal
+	fillsSize decimal.Decimal
+	closed    bool
+	// market children fill at once and could not be modified.
+	market bool
+}
+
+func newAlgo(client *goftx.Client, params Params, opts []Option) (*Algo, error) {
+	if params.Market == "" {
+		return nil, errors.New("market is missing")
+	}
+	if params.Side != models.Buy && params.Side != models.Sell {
+		return nil, errors.Errorf("side %v is invalid", params.Side)
+	}
+	if !params.Size.IsPositive() {
+		return nil, errors.New("size must be positive")
+	}
+
+	a := &Algo{
+		client:    client,
+		params:    params,
+		interval:  defaultInterval,
+		clientIDs: defaultClientIDs,
+		status:    Pending,
+		children:  make(map[int64]*child),
+		fills:     make(map[int64]bool),
+		watchers:  make(map[int]chan *Progress),
+		wakeC:     make(chan struct{}, 1),
+		doneC:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+	return a, nil
+}
+
+// Start subscribes to orders and fills and starts placing children. The algo is cancelled when ctx is done.
+func (a *Algo) Start(ctx context.Context) error {
+	a.mu.Lock()
+	if a.status != Pending {
+		a.mu.Unlock()
+		return errors.Errorf("algo is %v", a.status)
+	}
+	a.mu.Unlock()
+
+	if a.prepare != nil {
+		err := a.prepare()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ordersC, err := a.client.Stream.SubscribeToOrders(ctx)
+	if err != nil {
+		cancel()
+		return errors.WithStack(err)
+	}
+	fillsC, err := a.client.Stream.SubscribeToFills(ctx)
+	if err != nil {
+		cancel()
+		return errors.WithStack(err)
+	}
+
+	a.mu.Lock()
+	a.cancel = cancel
+	a.status = Running
+	a.startedAt = time.Now()
+	a.mu.Unlock()
+
+	go a.run(ctx, ordersC, fillsC)
+
+	return nil
+}
+
+func (a *Algo) run(ctx context.Context, ordersC chan *models.OrderResponse, fillsC chan *models.FillResponse) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	a.step()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = a.Cancel()
+			return
+		case order, ok := <-ordersC:
+			if !ok {
+				ordersC = nil
+				continue
+			}
+			a.applyOrder(order.Order)
+		case fill, ok := <-fillsC:
+			if !ok {
+				fillsC = nil
+				continue
+			}
+			a.applyFill(fill.Fill)
+		case <-a.wakeC:
+			a.step()
+		case <-ticker.C:
+			a.step()
+		}
+	}
+}
+
+func (a *Algo) wake() {
+	select {
+	case a.wakeC <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Algo) applyOrder(order models.Order) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.children[order.ID]
+	if !ok {
+		return
+	}
+	if order.FilledSize.GreaterThan(c.filled) {
+		c.filled = order.FilledSize
+	}
+	if order.Status == models.Closed {
+		c.closed = true
+		if a.working == c {
+			a.working = nil
+			a.wake()
+		}
+	}
+	a.notify()
+}
+
+func (a *Algo) applyFill(fill models.Fill) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.children[fill.OrderID]
+	if !ok || a.fills[fill.ID] {
+		return
+	}
+	a.fills[fill.ID] = true
+	c.fillsSize = c.fillsSize.Add(fill.Size)
+	if c.fillsSize.GreaterThan(c.filled) {
+		c.filled = c.fillsSize
+	}
+	a.fillSize = a.fillSize.Add(fill.Size)
+	a.notional = a.notional.Add(fill.Price.Mul(fill.Size))
+	if !c.filled.LessThan(c.size) && a.working == c {
+		a.working = nil
+	}
+	a.wake()
+	a.notify()
+}
+
+// step places, resizes or refills the working child to follow the schedule.
+// The working child is only replaced by step, Pause and Cancel, so it is stable while a.ops is held.
+func (a *Algo) step() {
+	a.ops.Lock()
+	defer a.ops.Unlock()
+
+	a.mu.Lock()
+	if a.status != Running {
+		a.mu.Unlock()
+		return
+	}
+	working := a.working
+	filled := a.filled()
+	target := a.targetSize()
+	a.mu.Unlock()
+
+	remaining := a.floor(a.params.Size.Sub(filled))
+	if !remaining.IsPositive() {
+		_ = a.cancelWorking()
+		a.finish(Completed)
+		return
+	}
+
+	var desired decimal.Decimal
+	if a.target == nil {
+		if working != nil {
+			return
+		}
+		desired = decimal.Min(a.displaySize, remaining)
+	} else {
+		desired = decimal.Min(a.floor(target.Sub(filled)), remaining)
+	}
+	if !desired.IsPositive() {
+		return
+	}
+
+	var (
+		c   *child
+		err error
+	)
+	switch {
+	case working == nil:
+		c, err = a.place(desired)
+	case working.market:
+		// the rest is placed once the working child is filled or closed
+		return
+	case desired.GreaterThan(working.size.Sub(working.filled)):
+		c, err = a.resize(working.id, desired)
+	default:
+		return
+	}
+
+	a.mu.Lock()
+	if err == nil {
+		if working != nil {
+			working.closed = true
+		}
+		a.working = c
+		a.children[c.id] = c
+		a.errs = 0
+		a.notify()
+		a.mu.Unlock()
+		return
+	}
+
+	a.errs++
+	a.lastErr = err
+	failed := a.errs >= maxChildErrors
+	if !failed {
+		a.notify()
+	}
+	a.mu.Unlock()
+
+	if failed {
+		_ = a.cancelWorking()
+		a.finish(Failed)
+	}
+}
+
+func (a *Algo) place(size decimal.Decimal) (*child, error) {
+	payload := &models.PlaceOrderPayload{
+		Market: a.params.Market,
+		Side:   a.params.Side,
+		Type:   models.MarketOrder,
+		Size:   size,
+	}
+	if a.params.LimitPrice.IsPositive() {
+		payload.Type = models.LimitOrder
+		payload.Price = a.params.LimitPrice
+	}
+	if a.params.ClientTag != "" {
+		clientID := a.clientIDs.Next(a.params.ClientTag)
+		payload.ClientID = &clientID
+	}
+
+	order, err := a.client.Orders.PlaceOrder(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &child{id: order.ID, size: size, market: payload.Type == models.MarketOrder}, nil
+}
+
+// resize grows the working child, a modified order is a new child since it gets a new ID.
+func (a *Algo) resize(id int64, size decimal.Decimal) (*child, error) {
+	order, err := a.client.Orders.ModifyOrder(&models.ModifyOrderPayload{Size: &size}, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &child{id: order.ID, size: size}, nil
+}
+
+func (a *Algo) filled() decimal.Decimal {
+	filled := decimal.Zero
+	for _, c := range a.children {
+		filled = filled.Add(c.filled)
+	}
+	return filled
+}
+
+func (a *Algo) targetSize() decimal.Decimal {
+	if a.target == nil || a.status == Pending {
+		return a.params.Size
+	}
+	elapsed := time.Since(a.startedAt) - a.paused
+	if a.status == Paused {
+		elapsed -= time.Since(a.pausedAt)
+	}
+	return decimal.Min(a.target(elapsed), a.params.Size)
+}
+
+// floor rounds size down to the size increment of the market if the metadata is known.
+func (a *Algo) floor(size decimal.Decimal) decimal.Decimal {
+	if a.metadata == nil {
+		return size
+	}
+	market, err := a.metadata.Lookup(a.params.Market)
+	if err != nil {
+		return size
+	}
+	return market.FloorSize(size)
+}
+
+// Pause cancels the working child and stops placing new ones, the schedule is shifted by the pause.
+// A working child that closed before it was cancelled does not fail the pause.
+func (a *Algo) Pause() error {
+	a.ops.Lock()
+	defer a.ops.Unlock()
+
+	a.mu.Lock()
+	status := a.status
+	a.mu.Unlock()
+	if status != Running {
+		return errors.Errorf("algo is %v", status)
+	}
+
+	err := a.cancelWorking()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.status = Paused
+	a.pausedAt = time.Now()
+	a.notify()
+	return nil
+}
+
+func (a *Algo) Resume() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.status != Paused {
+		return errors.Errorf("algo is %v", a.status)
+	}
+	a.paused += time.Since(a.pausedAt)
+	a.status = Running
+	a.wake()
+	a.notify()
+	return nil
+}
+
+// Cancel cancels the working child and stops the algo. It waits for a child request in flight,
+// which could only be cancelled once it is answered.
+func (a *Algo) Cancel() error {
+	a.ops.Lock()
+	defer a.ops.Unlock()
+
+	a.mu.Lock()
+	status := a.status
+	a.mu.Unlock()
+	switch status {
+	case Cancelled, Completed, Failed:
+		return nil
+	}
+
+	err := a.cancelWorking()
+	a.finish(Cancelled)
+	return errors.WithStack(err)
+}
+
+// cancelWorking cancels the working child with REST. Callers hold a.ops but not a.mu.
+func (a *Algo) cancelWorking() error {
+	a.mu.Lock()
+	working := a.working
+	a.mu.Unlock()
+	if working == nil {
+		return nil
+	}
+
+	var filled decimal.Decimal
+	err := a.client.Orders.CancelOrder(working.id)
+	if err != nil {
+		// the child could have closed before the orders channel told so
+		order, getErr := a.client.Orders.GetOrder(working.id)
+		if getErr != nil || order.Status != models.Closed {
+			return errors.WithStack(err)
+		}
+		filled = order.FilledSize
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if filled.GreaterThan(working.filled) {
+		working.filled = filled
+	}
+	working.closed = true
+	if a.working == working {
+		a.working = nil
+	}
+	return nil
+}
+
+func (a *Algo) finish(status Status) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.status = status
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.notify()
+	for id, progressC := range a.watchers {
+		close(progressC)
+		delete(a.watchers, id)
+	}
+	close(a.doneC)
+}
+
+// Done is closed once the algo is completed, cancelled or failed.
+func (a *Algo) Done() <-chan struct{} {
+	return a.doneC
+}
+
+func (a *Algo) Progress() Progress {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.progress()
+}
+
+func (a *Algo) progress() Progress {
+	p := Progress{
+		Status:     a.status,
+		Size:       a.params.Size,
+		FilledSize: a.filled(),
+		Target:     a.targetSize(),
+		Children:   len(a.children),
+		Err:        a.lastErr,
+		UpdatedAt:  time.Now(),
+	}
+	if a.fillSize.IsPositive() {
+		p.AvgFillPrice = a.notional.Div(a.fillSize)
+	}
+	return p
+}
+
+// SubscribeToProgress sends the progress on every change. Progress is dropped while the channel is full,
+// it is closed when ctx is done or the algo stops.
+func (a *Algo) SubscribeToProgress(ctx context.Context) chan *Progress {
+	progressC := make(chan *Progress, progressBuffer)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	select {
+	case <-a.doneC:
+		close(progressC)
+		return progressC
+	default:
+	}
+
+	id := a.nextWatcher
+	a.nextWatcher++
+	a.watchers[id] = progressC
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-a.doneC:
+			return
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if _, ok := a.watchers[id]; ok {
+			delete(a.watchers, id)
+			close(progressC)
+		}
+	}()
+
+	return progressC
+}
+
+func (a *Algo) notify() {
+	if len(a.watchers) == 0 {
+		return
+	}
+	p := a.progress()
+	for _, progressC := range a.watchers {
+		select {
+		case progressC <- &p:
+		default:
+		}
+	}
+}
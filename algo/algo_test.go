@@ -0,0 +1,391 @@
+package algo
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx"
+	"github.com/grishinsana/goftx/models"
+)
+
+// testExchange serves the order endpoints and the websocket of FTX over TLS,
+// clients are pointed to it by their dialers.
+type testExchange struct {
+	*httptest.Server
+	t *testing.T
+
+	mu       sync.Mutex
+	requests []string
+	sizes    []string
+	clients  []models.ClientID
+	nextID   int64
+	conns    map[models.Channel]*websocket.Conn
+	candles  []*models.HistoricalPrice
+	// closed orders could not be cancelled, they are returned with their filled size.
+	closed map[int64]decimal.Decimal
+	// placeGate holds new orders until it is closed.
+	placeGate chan struct{}
+}
+
+func newTestExchange(t *testing.T) *testExchange {
+	e := &testExchange{t: t, conns: make(map[models.Channel]*websocket.Conn), closed: make(map[int64]decimal.Decimal)}
+	e.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/ws") {
+			e.serveWS(w, r)
+			return
+		}
+		e.serveREST(w, r)
+	}))
+	return e
+}
+
+func (e *testExchange) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		e.t.Logf("upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var req models.WSRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		switch req.Op {
+		case models.Ping:
+			_ = conn.WriteJSON(map[string]interface{}{"type": models.Pong})
+		case models.Subscribe:
+			_ = conn.WriteJSON(map[string]interface{}{"type": models.Subscribed, "channel": req.Channel})
+			e.mu.Lock()
+			e.conns[req.Channel] = conn
+			e.mu.Unlock()
+		}
+	}
+}
+
+func (e *testExchange) serveREST(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	e.mu.Lock()
+	gate := e.placeGate
+	e.mu.Unlock()
+	if gate != nil && r.Method == http.MethodPost && path == "/orders" {
+		<-gate
+	}
+
+	e.mu.Lock()
+	var result interface{}
+	switch {
+	case r.Method == http.MethodPost && path == "/orders":
+		var payload models.PlaceOrderPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		e.nextID++
+		e.requests = append(e.requests, r.Method+" "+path)
+		e.sizes = append(e.sizes, payload.Size.String())
+		if payload.ClientID != nil {
+			e.clients = append(e.clients, *payload.ClientID)
+		}
+		result = models.Order{ID: e.nextID, Market: payload.Market, Size: payload.Size}
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[2] == "modify":
+		var payload models.ModifyOrderPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		e.nextID++
+		e.requests = append(e.requests, r.Method+" "+path)
+		e.sizes = append(e.sizes, payload.Size.String())
+		result = models.Order{ID: e.nextID, Size: *payload.Size}
+	case r.Method == http.MethodDelete && len(parts) == 2:
+		e.requests = append(e.requests, r.Method+" "+path)
+		id, _ := strconv.ParseInt(parts[1], 10, 64)
+		if _, ok := e.closed[id]; ok {
+			e.mu.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(goftx.Response{Error: "Order already closed"})
+			return
+		}
+	case r.Method == http.MethodGet && len(parts) == 2:
+		id, _ := strconv.ParseInt(parts[1], 10, 64)
+		order := models.Order{ID: id, Status: models.Open}
+		if filled, ok := e.closed[id]; ok {
+			order.Status, order.FilledSize = models.Closed, filled
+		}
+		result = order
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "candles":
+		result = e.candles
+	default:
+		e.mu.Unlock()
+		e.t.Logf("unexpected request %v %v", r.Method, path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	e.mu.Unlock()
+
+	data, _ := json.Marshal(result)
+	_ = json.NewEncoder(w).Encode(goftx.Response{Success: true, Result: data})
+}
+
+func (e *testExchange) client() *goftx.Client {
+	addr := e.Listener.Addr().String()
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+	return goftx.New(
+		goftx.WithAuth("key", "secret"),
+		goftx.WithHTTPClient(&http.Client{Transport: &http.Transport{DialContext: dial, TLSClientConfig: tlsConfig}}),
+		goftx.WithWebsocketDialer(&websocket.Dialer{NetDialContext: dial, TLSClientConfig: tlsConfig}),
+	)
+}
+
+func (e *testExchange) recorded() ([]string, []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]string(nil), e.requests...), append([]string(nil), e.sizes...)
+}
+
+// waitRequests waits for n requests changing orders.
+func (e *testExchange) waitRequests(n int) []string {
+	e.t.Helper()
+	var requests []string
+	require.Eventually(e.t, func() bool {
+		requests, _ = e.recorded()
+		return len(requests) >= n
+	}, 3*time.Second, 5*time.Millisecond)
+	return requests
+}
+
+func (e *testExchange) write(channel models.Channel, data map[string]interface{}) {
+	e.t.Helper()
+	var conn *websocket.Conn
+	require.Eventually(e.t, func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		conn = e.conns[channel]
+		return conn != nil
+	}, 3*time.Second, 5*time.Millisecond)
+	require.NoError(e.t, conn.WriteJSON(map[string]interface{}{
+		"channel": channel,
+		"type":    models.Update,
+		"data":    data,
+	}))
+}
+
+func (e *testExchange) writeFill(id, orderID int64, price, size float64) {
+	e.write(models.FillsChannel, map[string]interface{}{"id": id, "orderId": orderID, "market": "BTC-PERP", "price": price, "size": size})
+}
+
+func TestTWAPTarget(t *testing.T) {
+	size := decimal.NewFromInt(10)
+	for _, tt := range []struct {
+		elapsed time.Duration
+		want    string
+	}{
+		{0, "2.5"},
+		{time.Minute - 1, "2.5"},
+		{time.Minute, "5"},
+		{3 * time.Minute, "10"},
+		{time.Hour, "10"},
+	} {
+		require.Equal(t, tt.want, twapTarget(size, time.Minute, 4, tt.elapsed).String(), tt.elapsed)
+	}
+}
+
+func TestTWAP(t *testing.T) {
+	exchange := newTestExchange(t)
+	defer exchange.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	twap, err := NewTWAP(exchange.client(), Params{
+		Market:     "BTC-PERP",
+		Side:       models.Buy,
+		Size:       decimal.NewFromInt(3),
+		LimitPrice: decimal.NewFromInt(100),
+		ClientTag:  "twap",
+	}, 1500*time.Millisecond, 3, WithInterval(10*time.Millisecond), WithClientIDGenerator(goftx.NewClientIDSequence()))
+	require.NoError(t, err)
+	require.NoError(t, twap.Start(ctx))
+
+	// the first slice is placed at once and filled
+	exchange.waitRequests(1)
+	exchange.writeFill(100, 1, 99, 1)
+	exchange.writeFill(100, 1, 99, 1)
+
+	// the second slice is left unfilled and grown by the third one
+	requests := exchange.waitRequests(3)
+	require.Equal(t, []string{"POST /orders", "POST /orders", "POST /orders/2/modify"}, requests)
+	_, sizes := exchange.recorded()
+	require.Equal(t, []string{"1", "1", "2"}, sizes)
+	exchange.mu.Lock()
+	require.Len(t, exchange.clients, 2)
+	for i, clientID := range exchange.clients {
+		info := goftx.DecodeClientID(clientID)
+		require.Equal(t, "twap", info.Tag)
+		require.EqualValues(t, i+1, info.Sequence)
+	}
+	exchange.mu.Unlock()
+
+	exchange.writeFill(101, 3, 98, 2)
+	select {
+	case <-twap.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("twap is not completed")
+	}
+
+	progress := twap.Progress()
+	require.Equal(t, Completed, progress.Status)
+	require.Equal(t, "3", progress.FilledSize.String())
+	require.Equal(t, "0", progress.Remaining().String())
+	require.Equal(t, "98.3333333333333333", progress.AvgFillPrice.String())
+	require.Equal(t, 3, progress.Children)
+}
+
+func TestTWAP_MarketChildren(t *testing.T) {
+	exchange := newTestExchange(t)
+	defer exchange.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	twap, err := NewTWAP(exchange.client(), Params{
+		Market: "BTC-PERP",
+		Side:   models.Buy,
+		Size:   decimal.NewFromInt(3),
+	}, 600*time.Millisecond, 3, WithInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, twap.Start(ctx))
+
+	// a market child is not grown by the next slice
+	exchange.waitRequests(1)
+	time.Sleep(300 * time.Millisecond)
+	requests, _ := exchange.recorded()
+	require.Equal(t, []string{"POST /orders"}, requests)
+	require.Equal(t, Running, twap.Progress().Status)
+
+	// the rest is placed once the child closed
+	exchange.write(models.OrdersChannel, map[string]interface{}{"id": 1, "market": "BTC-PERP", "status": models.Closed, "size": 1, "filledSize": 1})
+	exchange.waitRequests(2)
+
+	// a child closed before the pause is not an error
+	exchange.mu.Lock()
+	exchange.closed[2] = decimal.NewFromInt(1)
+	exchange.mu.Unlock()
+	require.NoError(t, twap.Pause())
+
+	progress := twap.Progress()
+	require.Equal(t, Paused, progress.Status)
+	require.Equal(t, "2", progress.FilledSize.String())
+	requests, sizes := exchange.recorded()
+	require.Equal(t, []string{"POST /orders", "POST /orders", "DELETE /orders/2"}, requests)
+	require.Equal(t, []string{"1", "1"}, sizes)
+}
+
+func TestIceberg(t *testing.T) {
+	_, err := NewIceberg(nil, Params{Market: "BTC-PERP", Side: models.Sell, Size: decimal.NewFromInt(5)}, decimal.NewFromInt(2))
+	require.Error(t, err)
+
+	exchange := newTestExchange(t)
+	defer exchange.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	iceberg, err := NewIceberg(exchange.client(), Params{
+		Market:     "BTC-PERP",
+		Side:       models.Sell,
+		Size:       decimal.NewFromInt(5),
+		LimitPrice: decimal.NewFromInt(100),
+	}, decimal.NewFromInt(2), WithInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	progressC := iceberg.SubscribeToProgress(ctx)
+	require.NoError(t, iceberg.Start(ctx))
+
+	// a filled child is replaced
+	exchange.waitRequests(1)
+	exchange.writeFill(100, 1, 100, 2)
+	exchange.waitRequests(2)
+
+	require.NoError(t, iceberg.Pause())
+	require.Equal(t, Paused, iceberg.Progress().Status)
+	require.Error(t, iceberg.Pause())
+
+	require.NoError(t, iceberg.Resume())
+	exchange.waitRequests(4)
+
+	// a child closed by the orders channel is replaced by the rest
+	exchange.write(models.OrdersChannel, map[string]interface{}{"id": 3, "market": "BTC-PERP", "status": models.Closed, "size": 2, "filledSize": 2})
+	exchange.waitRequests(5)
+
+	require.NoError(t, iceberg.Cancel())
+	<-iceberg.Done()
+
+	requests, sizes := exchange.recorded()
+	require.Equal(t, []string{"POST /orders", "POST /orders", "DELETE /orders/2", "POST /orders", "POST /orders", "DELETE /orders/4"}, requests)
+	require.Equal(t, []string{"2", "2", "2", "1"}, sizes)
+
+	progress := iceberg.Progress()
+	require.Equal(t, Cancelled, progress.Status)
+	require.Equal(t, "4", progress.FilledSize.String())
+
+	var last *Progress
+	for p := range progressC {
+		last = p
+	}
+	require.NotNil(t, last)
+	require.Equal(t, Cancelled, last.Status)
+}
+
+func TestAlgo_Unlocked(t *testing.T) {
+	params := Params{Market: "BTC-PERP", Side: models.Buy, Size: decimal.NewFromInt(2), LimitPrice: decimal.NewFromInt(100)}
+	_, err := NewIceberg(nil, params, decimal.NewFromInt(1), WithInterval(0))
+	require.Error(t, err)
+
+	exchange := newTestExchange(t)
+	defer exchange.Close()
+	gate := make(chan struct{})
+	exchange.placeGate = gate
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	iceberg, err := NewIceberg(exchange.client(), params, decimal.NewFromInt(1), WithInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, iceberg.Start(ctx))
+
+	// the progress is not held by the child being placed
+	time.Sleep(50 * time.Millisecond)
+	progressC := make(chan Progress, 1)
+	go func() {
+		progressC <- iceberg.Progress()
+	}()
+	select {
+	case progress := <-progressC:
+		require.Equal(t, Running, progress.Status)
+		require.Equal(t, 0, progress.Children)
+	case <-time.After(time.Second):
+		t.Fatal("algo is locked by a child request")
+	}
+
+	close(gate)
+	exchange.waitRequests(1)
+	require.NoError(t, iceberg.Cancel())
+	require.Equal(t, 1, iceberg.Progress().Children)
+}
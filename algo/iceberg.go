@@ -0,0 +1,26 @@
+package algo
+
+import (
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx"
+)
+
+// NewIceberg shows at most displaySize of the parent order at Params.LimitPrice
+// and places the next child once the working one is filled or closed.
+func NewIceberg(client *goftx.Client, params Params, displaySize decimal.Decimal, opts ...Option) (*Algo, error) {
+	if !params.LimitPrice.IsPositive() {
+		return nil, errors.New("iceberg requires a limit price")
+	}
+	if !displaySize.IsPositive() {
+		return nil, errors.New("display size must be positive")
+	}
+
+	a, err := newAlgo(client, params, opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a.displaySize = displaySize
+	return a, nil
+}
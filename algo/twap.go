@@ -0,0 +1,44 @@
+package algo
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx"
+)
+
+// NewTWAP splits the parent order into equal slices over duration. The first slice is placed at start,
+// the target grows by a slice at the beginning of every following slice interval and an unfilled rest
+// is carried over by resizing the working child.
+func NewTWAP(client *goftx.Client, params Params, duration time.Duration, slices int, opts ...Option) (*Algo, error) {
+	if duration <= 0 {
+		return nil, errors.New("duration must be positive")
+	}
+	if slices <= 0 {
+		return nil, errors.New("slices must be positive")
+	}
+
+	a, err := newAlgo(client, params, opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sliceDuration := duration / time.Duration(slices)
+	if sliceDuration > 0 && a.interval > sliceDuration {
+		a.interval = sliceDuration
+	}
+	a.target = func(elapsed time.Duration) decimal.Decimal {
+		return twapTarget(params.Size, sliceDuration, slices, elapsed)
+	}
+	return a, nil
+}
+
+func twapTarget(size decimal.Decimal, sliceDuration time.Duration, slices int, elapsed time.Duration) decimal.Decimal {
+	passed := slices
+	if sliceDuration > 0 && elapsed < sliceDuration*time.Duration(slices) {
+		passed = int(elapsed/sliceDuration) + 1
+	}
+	return size.Mul(decimal.NewFromInt(int64(passed))).Div(decimal.NewFromInt(int64(slices)))
+}
@@ -0,0 +1,154 @@
+package algo
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx"
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	defaultVWAPLookback   = 7
+	defaultVWAPResolution = models.Minute5
+	maxHistoricalPrices   = 5000
+)
+
+// WithVWAPLookback sets the number of days of candles used to build the volume profile.
+func WithVWAPLookback(days int) Option {
+	return func(a *Algo) {
+		a.lookback = days
+	}
+}
+
+// WithVWAPResolution sets the size of the volume profile buckets.
+func WithVWAPResolution(resolution models.Resolution) Option {
+	return func(a *Algo) {
+		a.resolution = resolution
+	}
+}
+
+// NewVWAP follows the time of day volume profile of the market over duration. The profile is built from
+// GetHistoricalPrices at Start, the schedule is linear when the market has no volume in the window.
+func NewVWAP(client *goftx.Client, params Params, duration time.Duration, opts ...Option) (*Algo, error) {
+	if duration <= 0 {
+		return nil, errors.New("duration must be positive")
+	}
+
+	a, err := newAlgo(client, params, append([]Option{
+		WithVWAPLookback(defaultVWAPLookback),
+		WithVWAPResolution(defaultVWAPResolution),
+	}, opts...))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if a.lookback <= 0 {
+		return nil, errors.New("lookback must be positive")
+	}
+	if a.resolution <= 0 || models.Day%a.resolution != 0 {
+		return nil, errors.Errorf("resolution %v does not divide a day", a.resolution)
+	}
+
+	var profile volumeProfile
+	a.prepare = func() error {
+		end := time.Now()
+		start := end.AddDate(0, 0, -a.lookback)
+		prices, err := loadHistoricalPrices(client, params.Market, a.resolution, start, end)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		profile = newVolumeProfile(prices, a.resolution)
+		return nil
+	}
+	a.target = func(elapsed time.Duration) decimal.Decimal {
+		// lead by an interval so the child for the coming interval is already working
+		return profile.target(params.Size, a.startedAt, duration, elapsed+a.interval)
+	}
+	return a, nil
+}
+
+func loadHistoricalPrices(client *goftx.Client, market string, resolution models.Resolution, start, end time.Time) ([]*models.HistoricalPrice, error) {
+	var result []*models.HistoricalPrice
+	step := time.Duration(resolution) * time.Second * maxHistoricalPrices
+	for from := start; from.Before(end); from = from.Add(step) {
+		to := from.Add(step)
+		if to.After(end) {
+			to = end
+		}
+		startTime, endTime, limit := int(from.Unix()), int(to.Unix()), maxHistoricalPrices
+		prices, err := client.Markets.GetHistoricalPrices(market, &models.GetHistoricalPricesParams{
+			Resolution: resolution,
+			Limit:      &limit,
+			StartTime:  &startTime,
+			EndTime:    &endTime,
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		result = append(result, prices...)
+	}
+	return result, nil
+}
+
+// volumeProfile is the share of volume traded in every bucket of a UTC day.
+type volumeProfile struct {
+	resolution time.Duration
+	volumes    []decimal.Decimal
+}
+
+func newVolumeProfile(prices []*models.HistoricalPrice, resolution models.Resolution) volumeProfile {
+	p := volumeProfile{
+		resolution: time.Duration(resolution) * time.Second,
+		volumes:    make([]decimal.Decimal, models.Day/resolution),
+	}
+	seen := make(map[int64]bool, len(prices))
+	for _, price := range prices {
+		// pages overlap at their bounds
+		if seen[price.StartTime.Unix()] {
+			continue
+		}
+		seen[price.StartTime.Unix()] = true
+		bucket := p.bucket(price.StartTime)
+		p.volumes[bucket] = p.volumes[bucket].Add(price.Volume)
+	}
+	return p
+}
+
+func (p volumeProfile) bucket(t time.Time) int {
+	t = t.UTC()
+	return int(t.Sub(t.Truncate(24*time.Hour)) / p.resolution)
+}
+
+// volume returns the profile volume between from and to, buckets are split proportionally.
+func (p volumeProfile) volume(from, to time.Time) decimal.Decimal {
+	total := decimal.Zero
+	if len(p.volumes) == 0 {
+		return total
+	}
+	for t := from; t.Before(to); {
+		end := t.Truncate(p.resolution).Add(p.resolution)
+		if end.After(to) {
+			end = to
+		}
+		share := decimal.NewFromInt(int64(end.Sub(t))).Div(decimal.NewFromInt(int64(p.resolution)))
+		total = total.Add(p.volumes[p.bucket(t)].Mul(share))
+		t = end
+	}
+	return total
+}
+
+func (p volumeProfile) target(size decimal.Decimal, start time.Time, duration, elapsed time.Duration) decimal.Decimal {
+	if elapsed >= duration {
+		return size
+	}
+	if elapsed <= 0 {
+		return decimal.Zero
+	}
+	total := p.volume(start, start.Add(duration))
+	if !total.IsPositive() {
+		return size.Mul(decimal.NewFromInt(int64(elapsed))).Div(decimal.NewFromInt(int64(duration)))
+	}
+	return size.Mul(p.volume(start, start.Add(elapsed))).Div(total)
+}
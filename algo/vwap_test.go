@@ -0,0 +1,72 @@
+package algo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestVolumeProfile(t *testing.T) {
+	day := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(start time.Time, volume int64) *models.HistoricalPrice {
+		return &models.HistoricalPrice{StartTime: start, Volume: decimal.NewFromInt(volume)}
+	}
+	profile := newVolumeProfile([]*models.HistoricalPrice{
+		candle(day, 10),
+		candle(day.Add(time.Hour), 30),
+		candle(day.Add(time.Hour), 30), // overlapping pages
+		candle(day.AddDate(0, 0, 1), 10),
+		candle(day.AddDate(0, 0, 1).Add(time.Hour), 30),
+	}, models.Hour)
+
+	size := decimal.NewFromInt(8)
+	start := day.AddDate(0, 0, 7)
+	for _, tt := range []struct {
+		elapsed time.Duration
+		want    string
+	}{
+		{0, "0"},
+		{30 * time.Minute, "1"},
+		{time.Hour, "2"},
+		{90 * time.Minute, "5"},
+		{2 * time.Hour, "8"},
+	} {
+		require.Equal(t, tt.want, profile.target(size, start, 2*time.Hour, tt.elapsed).String(), tt.elapsed)
+	}
+
+	// linear without volume in the window
+	require.Equal(t, "2", profile.target(size, start.Add(12*time.Hour), 2*time.Hour, 30*time.Minute).String())
+}
+
+func TestVWAP(t *testing.T) {
+	_, err := NewVWAP(nil, Params{Market: "BTC-PERP", Side: models.Buy, Size: decimal.NewFromInt(1)}, time.Hour, WithVWAPResolution(7))
+	require.Error(t, err)
+
+	exchange := newTestExchange(t)
+	defer exchange.Close()
+
+	// all the volume of the day is traded in the current hour
+	now := time.Now().UTC()
+	exchange.candles = []*models.HistoricalPrice{{StartTime: now.Truncate(time.Hour), Volume: decimal.NewFromInt(100)}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vwap, err := NewVWAP(exchange.client(), Params{
+		Market: "BTC-PERP",
+		Side:   models.Buy,
+		Size:   decimal.NewFromInt(4),
+	}, 2*time.Hour, WithVWAPResolution(models.Hour), WithInterval(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, vwap.Start(ctx))
+
+	exchange.waitRequests(1)
+	_, sizes := exchange.recorded()
+	require.Equal(t, []string{"4"}, sizes)
+	require.Equal(t, "4", vwap.Progress().Target.String())
+}
@@ -0,0 +1,791 @@
+package goftx
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const bracketReconcileInterval = time.Second * 30
+
+type BracketStatus string
+
+const (
+	// BracketPending is waiting for the entry order to fill.
+	BracketPending = BracketStatus("pending")
+	// BracketActive has its take profit and stop loss placed.
+	BracketActive = BracketStatus("active")
+	// BracketClosed is flat or was cancelled.
+	BracketClosed = BracketStatus("closed")
+)
+
+// BracketPayload is an entry order with the trigger prices of its exit legs.
+type BracketPayload struct {
+	Entry      models.PlaceOrderPayload
+	TakeProfit decimal.Decimal
+	StopLoss   decimal.Decimal
+}
+
+// Bracket is the state of an entry order and its take profit and stop loss legs.
+// Fills are kept by fill ID, so fills seen both on the stream and with REST are counted once.
+type Bracket struct {
+	ID             int64                     `json:"id"`
+	Market         string                    `json:"market"`
+	Side           models.Side               `json:"side"`
+	Status         BracketStatus             `json:"status"`
+	EntryOrderID   int64                     `json:"entryOrderId"`
+	EntryClosed    bool                      `json:"entryClosed"`
+	EntryFills     map[int64]decimal.Decimal `json:"entryFills"`
+	TakeProfit     decimal.Decimal           `json:"takeProfit"`
+	StopLoss       decimal.Decimal           `json:"stopLoss"`
+	TakeProfitID   int64                     `json:"takeProfitId"`
+	TakeProfitSize decimal.Decimal           `json:"takeProfitSize"`
+	StopLossID     int64                     `json:"stopLossId"`
+	StopLossSize   decimal.Decimal           `json:"stopLossSize"`
+	TriggeredID    int64                     `json:"triggeredId"`
+	ExitOrderIDs   []int64                   `json:"exitOrderIds"`
+	ExitFills      map[int64]decimal.Decimal `json:"exitFills"`
+	CreatedAt      time.Time                 `json:"createdAt"`
+	UpdatedAt      time.Time                 `json:"updatedAt"`
+}
+
+// FilledSize is the size filled by the entry order.
+func (b *Bracket) FilledSize() decimal.Decimal {
+	return sumFills(b.EntryFills)
+}
+
+// ExitedSize is the size filled by the legs.
+func (b *Bracket) ExitedSize() decimal.Decimal {
+	return sumFills(b.ExitFills)
+}
+
+func (b *Bracket) copy() *Bracket {
+	c := *b
+	c.EntryFills = copyFills(b.EntryFills)
+	c.ExitFills = copyFills(b.ExitFills)
+	c.ExitOrderIDs = append([]int64(nil), b.ExitOrderIDs...)
+	return &c
+}
+
+func (b *Bracket) isExitOrder(orderID int64) bool {
+	for _, id := range b.ExitOrderIDs {
+		if id == orderID {
+			return true
+		}
+	}
+	return false
+}
+
+func sumFills(fills map[int64]decimal.Decimal) decimal.Decimal {
+	sum := decimal.Zero
+	for _, size := range fills {
+		sum = sum.Add(size)
+	}
+	return sum
+}
+
+func copyFills(fills map[int64]decimal.Decimal) map[int64]decimal.Decimal {
+	c := make(map[int64]decimal.Decimal, len(fills))
+	for id, size := range fills {
+		c[id] = size
+	}
+	return c
+}
+
+// BracketStore persists open brackets, so a restarted manager resumes them.
+type BracketStore interface {
+	Load() ([]*Bracket, error)
+	Save(brackets []*Bracket) error
+}
+
+// FileBracketStore keeps brackets in a JSON file, which is replaced atomically on every save.
+type FileBracketStore struct {
+	path string
+}
+
+func NewFileBracketStore(path string) *FileBracketStore {
+	return &FileBracketStore{path: path}
+}
+
+func (s *FileBracketStore) Load() ([]*Bracket, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var brackets []*Bracket
+	err = json.Unmarshal(data, &brackets)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return brackets, nil
+}
+
+func (s *FileBracketStore) Save(brackets []*Bracket) error {
+	data, err := json.Marshal(brackets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(tmp.Name(), s.path))
+}
+
+type BracketManagerOption func(m *BracketManager)
+
+// WithBracketStore persists brackets, so Start resumes the open ones.
+func WithBracketStore(store BracketStore) BracketManagerOption {
+	return func(m *BracketManager) {
+		m.store = store
+	}
+}
+
+// WithBracketReconcileInterval sets how often brackets are checked with REST, which catches
+// entries cancelled without fills and retries failed leg updates. Zero disables it.
+func WithBracketReconcileInterval(interval time.Duration) BracketManagerOption {
+	return func(m *BracketManager) {
+		m.reconcileInterval = interval
+	}
+}
+
+// BracketManager implements brackets on the client side: once the entry order fills, a reduce only
+// take profit and stop loss are placed and resized to the filled size. When a leg executes
+// the other one is cancelled, as is the rest of the entry order.
+//
+// REST requests are made without holding the manager lock, requests for a single bracket
+// are serialized by its own lock.
+type BracketManager struct {
+	client            *Client
+	store             BracketStore
+	reconcileInterval time.Duration
+
+	mu       sync.Mutex
+	brackets map[int64]*Bracket
+	ops      map[int64]*sync.Mutex
+	// dirty brackets got fills which advance has not seen yet.
+	dirty map[int64]bool
+	// placing counts entry orders being placed, their early fills are kept in unattributed.
+	placing      int
+	unattributed []models.Fill
+}
+
+func NewBracketManager(client *Client, opts ...BracketManagerOption) *BracketManager {
+	m := &BracketManager{
+		client:            client,
+		reconcileInterval: bracketReconcileInterval,
+		brackets:          make(map[int64]*Bracket),
+		ops:               make(map[int64]*sync.Mutex),
+		dirty:             make(map[int64]bool),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Start loads stored brackets, catches up on their fills with REST and manages them until ctx is done.
+func (m *BracketManager) Start(ctx context.Context) error {
+	fillsC, err := m.client.Stream.SubscribeToFills(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if m.store != nil {
+		brackets, err := m.store.Load()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		m.mu.Lock()
+		for _, bracket := range brackets {
+			if bracket.EntryFills == nil {
+				bracket.EntryFills = make(map[int64]decimal.Decimal)
+			}
+			if bracket.ExitFills == nil {
+				bracket.ExitFills = make(map[int64]decimal.Decimal)
+			}
+			m.brackets[bracket.ID] = bracket
+		}
+		m.mu.Unlock()
+	}
+
+	m.reconcile()
+
+	go m.run(ctx, fillsC)
+	if m.reconcileInterval > 0 {
+		go m.reconcileEvery(ctx, m.reconcileInterval)
+	}
+
+	return nil
+}
+
+func (m *BracketManager) run(ctx context.Context, fillsC chan *models.FillResponse) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fill, ok := <-fillsC:
+			if !ok {
+				return
+			}
+			m.applyFill(fill.Fill)
+		}
+	}
+}
+
+func (m *BracketManager) reconcileEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reconcile()
+		}
+	}
+}
+
+// PlaceBracket places the entry order and manages its legs.
+func (m *BracketManager) PlaceBracket(payload *BracketPayload) (*Bracket, error) {
+	if !payload.TakeProfit.IsPositive() || !payload.StopLoss.IsPositive() {
+		return nil, errors.New("takeProfit and stopLoss must be positive")
+	}
+	if payload.Entry.Side == models.Buy && !payload.TakeProfit.GreaterThan(payload.StopLoss) {
+		return nil, errors.New("takeProfit must be above stopLoss for buys")
+	}
+	if payload.Entry.Side == models.Sell && !payload.TakeProfit.LessThan(payload.StopLoss) {
+		return nil, errors.New("takeProfit must be below stopLoss for sells")
+	}
+
+	// fills which arrive before the order ID is known are kept until the order is placed
+	m.mu.Lock()
+	m.placing++
+	m.mu.Unlock()
+
+	order, err := m.client.Orders.PlaceOrder(&payload.Entry)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	early := m.unattributed
+	m.placing--
+	if m.placing == 0 {
+		m.unattributed = nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := time.Now()
+	bracket := &Bracket{
+		ID:           order.ID,
+		Market:       payload.Entry.Market,
+		Side:         payload.Entry.Side,
+		Status:       BracketPending,
+		EntryOrderID: order.ID,
+		EntryFills:   make(map[int64]decimal.Decimal),
+		TakeProfit:   payload.TakeProfit,
+		StopLoss:     payload.StopLoss,
+		ExitFills:    make(map[int64]decimal.Decimal),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	for _, fill := range early {
+		if fill.OrderID == order.ID {
+			bracket.EntryFills[fill.ID] = fill.Size
+		}
+	}
+	m.brackets[bracket.ID] = bracket
+	m.save()
+
+	if len(bracket.EntryFills) > 0 {
+		m.dirty[bracket.ID] = true
+		go m.process(bracket.ID)
+	}
+
+	return bracket.copy(), nil
+}
+
+// CancelBracket cancels the entry order and the legs of a bracket, leaving an open position as is.
+func (m *BracketManager) CancelBracket(id int64) error {
+	ops := m.opsLock(id)
+	ops.Lock()
+	defer ops.Unlock()
+
+	m.mu.Lock()
+	bracket, ok := m.brackets[id]
+	if !ok {
+		m.mu.Unlock()
+		return errors.Errorf("bracket %v is unknown", id)
+	}
+	if bracket.Status == BracketClosed {
+		m.mu.Unlock()
+		return nil
+	}
+	snapshot := bracket.copy()
+	m.mu.Unlock()
+
+	if !snapshot.EntryClosed {
+		err := m.client.Orders.CancelOrder(snapshot.EntryOrderID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	err := m.cancelLegs(snapshot, 0)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bracket.EntryClosed = true
+	if err != nil {
+		m.save()
+		return errors.WithStack(err)
+	}
+	bracket.Status = BracketClosed
+	bracket.UpdatedAt = time.Now()
+	m.save()
+
+	return nil
+}
+
+func (m *BracketManager) Get(id int64) (*Bracket, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bracket, ok := m.brackets[id]
+	if !ok {
+		return nil, false
+	}
+	return bracket.copy(), true
+}
+
+// Brackets returns every bracket known since Start sorted by ID.
+func (m *BracketManager) Brackets() []*Bracket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]*Bracket, 0, len(m.brackets))
+	for _, bracket := range m.brackets {
+		result = append(result, bracket.copy())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// opsLock returns the lock which serializes the REST requests of bracket id.
+func (m *BracketManager) opsLock(id int64) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops, ok := m.ops[id]
+	if !ok {
+		ops = &sync.Mutex{}
+		m.ops[id] = ops
+	}
+	return ops
+}
+
+// applyFill books a fill of an entry or a known exit order. A fill on the opposite side could come from
+// an order triggered by a leg, the legs are then checked with REST without holding the lock.
+func (m *BracketManager) applyFill(fill models.Fill) {
+	m.mu.Lock()
+	var candidates []bracketLegs
+	for _, bracket := range m.brackets {
+		if bracket.Status == BracketClosed || bracket.Market != fill.Market {
+			continue
+		}
+
+		switch {
+		case fill.OrderID == bracket.EntryOrderID:
+			bracket.EntryFills[fill.ID] = fill.Size
+		case bracket.isExitOrder(fill.OrderID):
+			bracket.ExitFills[fill.ID] = fill.Size
+		default:
+			if fill.Side != bracket.Side && (bracket.TakeProfitID != 0 || bracket.StopLossID != 0) {
+				candidates = append(candidates, bracketLegs{
+					bracketID: bracket.ID,
+					legIDs:    []int64{bracket.TakeProfitID, bracket.StopLossID},
+				})
+			}
+			continue
+		}
+
+		m.dirty[bracket.ID] = true
+		m.save()
+		m.mu.Unlock()
+		go m.process(bracket.ID)
+		return
+	}
+	if m.placing > 0 {
+		m.unattributed = append(m.unattributed, fill)
+	}
+	m.mu.Unlock()
+
+	for _, candidate := range candidates {
+		legID, ok := m.identifyLeg(candidate, fill.OrderID)
+		if !ok {
+			continue
+		}
+
+		m.mu.Lock()
+		bracket, ok := m.brackets[candidate.bracketID]
+		if ok && bracket.Status != BracketClosed {
+			bracket.TriggeredID = legID
+			if !bracket.isExitOrder(fill.OrderID) {
+				bracket.ExitOrderIDs = append(bracket.ExitOrderIDs, fill.OrderID)
+			}
+			bracket.ExitFills[fill.ID] = fill.Size
+			m.dirty[bracket.ID] = true
+			m.save()
+		}
+		m.mu.Unlock()
+		go m.process(candidate.bracketID)
+		return
+	}
+}
+
+// bracketLegs are the leg IDs of a bracket at the time a fill was received.
+type bracketLegs struct {
+	bracketID int64
+	legIDs    []int64
+}
+
+// identifyLeg checks with REST whether orderID was triggered by one of the legs and returns the leg ID.
+func (m *BracketManager) identifyLeg(legs bracketLegs, orderID int64) (int64, bool) {
+	for _, legID := range legs.legIDs {
+		if legID == 0 {
+			continue
+		}
+		triggers, err := m.client.Orders.GetOrderTriggers(legID)
+		if err != nil {
+			m.client.Stream.printf("bracket %v triggers of %v: %+v", legs.bracketID, legID, err)
+			continue
+		}
+		for _, trigger := range triggers {
+			if trigger.OrderID == orderID {
+				return legID, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// process advances bracket id until it has seen every booked fill.
+func (m *BracketManager) process(id int64) {
+	ops := m.opsLock(id)
+	ops.Lock()
+	defer ops.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.advanceDirty(id)
+}
+
+// advanceDirty runs advance on a copy of bracket id without holding m.mu and applies the result.
+// Callers hold m.mu and the ops lock of the bracket.
+func (m *BracketManager) advanceDirty(id int64) {
+	for m.dirty[id] {
+		delete(m.dirty, id)
+		bracket, ok := m.brackets[id]
+		if !ok || bracket.Status == BracketClosed {
+			return
+		}
+
+		snapshot := bracket.copy()
+		m.mu.Unlock()
+		m.advance(snapshot)
+		m.mu.Lock()
+
+		bracket.applyOrders(snapshot)
+		m.save()
+	}
+}
+
+// reconcile catches up on fills and closed entries of open brackets with REST and advances them.
+func (m *BracketManager) reconcile() {
+	m.mu.Lock()
+	ids := make([]int64, 0, len(m.brackets))
+	for id, bracket := range m.brackets {
+		if bracket.Status != BracketClosed {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		m.reconcileBracket(id)
+	}
+}
+
+func (m *BracketManager) reconcileBracket(id int64) {
+	ops := m.opsLock(id)
+	ops.Lock()
+	defer ops.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bracket, ok := m.brackets[id]
+	if !ok || bracket.Status == BracketClosed {
+		return
+	}
+	snapshot := bracket.copy()
+
+	m.mu.Unlock()
+	err := m.catchUp(snapshot)
+	m.mu.Lock()
+
+	if err != nil {
+		m.client.Stream.printf("bracket %v reconcile: %+v", id, err)
+		return
+	}
+	bracket.applyCatchUp(snapshot)
+	m.dirty[id] = true
+	m.advanceDirty(id)
+	m.save()
+}
+
+func (m *BracketManager) catchUp(bracket *Bracket) error {
+	if !bracket.EntryClosed {
+		entry, err := m.client.Orders.GetOrder(bracket.EntryOrderID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		bracket.EntryClosed = entry.Status == models.Closed
+	}
+	err := m.catchUpFills(bracket.EntryOrderID, bracket.EntryFills)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, legID := range []int64{bracket.TakeProfitID, bracket.StopLossID} {
+		if legID == 0 {
+			continue
+		}
+		triggers, err := m.client.Orders.GetOrderTriggers(legID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, trigger := range triggers {
+			if trigger.OrderID == 0 || bracket.isExitOrder(trigger.OrderID) {
+				continue
+			}
+			bracket.TriggeredID = legID
+			bracket.ExitOrderIDs = append(bracket.ExitOrderIDs, trigger.OrderID)
+		}
+	}
+	for _, orderID := range bracket.ExitOrderIDs {
+		err = m.catchUpFills(orderID, bracket.ExitFills)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func (m *BracketManager) catchUpFills(orderID int64, fills map[int64]decimal.Decimal) error {
+	result, err := m.client.Fills.GetFills(&models.GetFillsParams{OrderID: &orderID})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, fill := range result {
+		fills[fill.ID] = fill.Size
+	}
+	return nil
+}
+
+// applyCatchUp merges the fills and exit orders found by catchUp on a copy of b.
+func (b *Bracket) applyCatchUp(c *Bracket) {
+	b.EntryClosed = b.EntryClosed || c.EntryClosed
+	for id, size := range c.EntryFills {
+		b.EntryFills[id] = size
+	}
+	for id, size := range c.ExitFills {
+		b.ExitFills[id] = size
+	}
+	for _, orderID := range c.ExitOrderIDs {
+		if !b.isExitOrder(orderID) {
+			b.ExitOrderIDs = append(b.ExitOrderIDs, orderID)
+		}
+	}
+	if c.TriggeredID != 0 {
+		b.TriggeredID = c.TriggeredID
+	}
+}
+
+// applyOrders copies the order state changed by advance on a copy of b. Fills are left as is,
+// they are only booked on b.
+func (b *Bracket) applyOrders(c *Bracket) {
+	b.Status = c.Status
+	b.EntryClosed = b.EntryClosed || c.EntryClosed
+	b.TakeProfitID, b.TakeProfitSize = c.TakeProfitID, c.TakeProfitSize
+	b.StopLossID, b.StopLossSize = c.StopLossID, c.StopLossSize
+	b.UpdatedAt = c.UpdatedAt
+}
+
+// advance brings the orders of bracket in line with its fills. Failed requests are logged
+// and retried on the next fill or reconciliation.
+func (m *BracketManager) advance(bracket *Bracket) {
+	filled := bracket.FilledSize()
+	exited := bracket.ExitedSize()
+	open := filled.Sub(exited)
+	bracket.UpdatedAt = time.Now()
+
+	switch {
+	case exited.IsPositive():
+		// a leg executed, the bracket is winding down
+		if !bracket.EntryClosed {
+			err := m.client.Orders.CancelOrder(bracket.EntryOrderID)
+			if err != nil {
+				m.client.Stream.printf("bracket %v cancel entry: %+v", bracket.ID, err)
+				return
+			}
+			bracket.EntryClosed = true
+		}
+		if open.IsPositive() {
+			m.resizeLegs(bracket, open)
+			return
+		}
+		err := m.cancelLegs(bracket, bracket.TriggeredID)
+		if err != nil {
+			m.client.Stream.printf("bracket %v cancel legs: %+v", bracket.ID, err)
+			return
+		}
+		bracket.Status = BracketClosed
+	case filled.IsPositive():
+		if m.placeLegs(bracket, open) {
+			m.resizeLegs(bracket, open)
+		}
+	case bracket.EntryClosed:
+		bracket.Status = BracketClosed
+	}
+}
+
+type bracketLeg struct {
+	id          *int64
+	size        *decimal.Decimal
+	triggerType models.TriggerOrderType
+	price       decimal.Decimal
+}
+
+func (b *Bracket) legs() []bracketLeg {
+	return []bracketLeg{
+		{id: &b.TakeProfitID, size: &b.TakeProfitSize, triggerType: models.TakeProfit, price: b.TakeProfit},
+		{id: &b.StopLossID, size: &b.StopLossSize, triggerType: models.Stop, price: b.StopLoss},
+	}
+}
+
+// placeLegs places the legs which are not placed yet and reports whether both are placed.
+func (m *BracketManager) placeLegs(bracket *Bracket, size decimal.Decimal) bool {
+	reduceOnly := true
+	for _, leg := range bracket.legs() {
+		if *leg.id != 0 {
+			continue
+		}
+		price := leg.price
+		trigger, err := m.client.Orders.PlaceTriggerOrder(&models.PlaceTriggerOrderPayload{
+			Market:       bracket.Market,
+			Side:         bracket.Side.Opposite(),
+			Size:         size,
+			Type:         leg.triggerType,
+			ReduceOnly:   &reduceOnly,
+			TriggerPrice: &price,
+		})
+		if err != nil {
+			m.client.Stream.printf("bracket %v place %v: %+v", bracket.ID, leg.triggerType, err)
+			return false
+		}
+		*leg.id = trigger.ID
+		*leg.size = size
+		bracket.Status = BracketActive
+	}
+	return true
+}
+
+// resizeLegs modifies the legs which did not trigger to size, a modified trigger order gets a new ID.
+func (m *BracketManager) resizeLegs(bracket *Bracket, size decimal.Decimal) {
+	for _, leg := range bracket.legs() {
+		if *leg.id == 0 || *leg.id == bracket.TriggeredID || leg.size.Equal(size) {
+			continue
+		}
+		trigger, err := m.client.Orders.ModifyTriggerOrder(&models.ModifyTriggerOrderPayload{
+			Size:         size,
+			TriggerPrice: leg.price,
+		}, *leg.id)
+		if err != nil {
+			m.client.Stream.printf("bracket %v resize leg %v: %+v", bracket.ID, *leg.id, err)
+			return
+		}
+		*leg.id = trigger.ID
+		*leg.size = size
+	}
+}
+
+// cancelLegs cancels every leg except the one with the skip ID.
+func (m *BracketManager) cancelLegs(bracket *Bracket, skip int64) error {
+	for _, legID := range []int64{bracket.TakeProfitID, bracket.StopLossID} {
+		if legID == 0 || legID == skip {
+			continue
+		}
+		err := m.client.Orders.CancelOpenTriggerOrder(legID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// save persists the brackets which are not closed.
+func (m *BracketManager) save() {
+	if m.store == nil {
+		return
+	}
+
+	brackets := make([]*Bracket, 0, len(m.brackets))
+	for _, bracket := range m.brackets {
+		if bracket.Status != BracketClosed {
+			brackets = append(brackets, bracket)
+		}
+	}
+	sort.Slice(brackets, func(i, j int) bool {
+		return brackets[i].ID < brackets[j].ID
+	})
+
+	err := m.store.Save(brackets)
+	if err != nil {
+		m.client.Stream.printf("save brackets: %+v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package goftx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// bracketTestAPI fakes the order endpoints used by brackets and records the requests that change orders.
+type bracketTestAPI struct {
+	mu        sync.Mutex
+	requests  []string
+	triggers  map[int64][]models.Trigger
+	fills     map[int64][]models.Fill
+	nextLegID int64
+	// triggersGate holds trigger lookups until it is closed.
+	triggersGate chan struct{}
+	// beforePlace runs before the entry order is answered.
+	beforePlace func()
+}
+
+func (a *bracketTestAPI) record(r *http.Request, body interface{}) {
+	if body != nil {
+		_ = json.NewDecoder(r.Body).Decode(body)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests = append(a.requests, r.Method+" "+r.URL.Path)
+}
+
+func (a *bracketTestAPI) recorded() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.requests...)
+}
+
+func (a *bracketTestAPI) routes() map[string]testRoute {
+	leg := func(r *http.Request) (interface{}, error) {
+		var payload models.PlaceTriggerOrderPayload
+		a.record(r, &payload)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.nextLegID++
+		return models.TriggerOrder{ID: a.nextLegID, Market: payload.Market, Size: payload.Size}, nil
+	}
+	triggers := func(id int64) testRoute {
+		return func(r *http.Request) (interface{}, error) {
+			a.mu.Lock()
+			gate := a.triggersGate
+			a.mu.Unlock()
+			if gate != nil {
+				<-gate
+			}
+
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			return a.triggers[id], nil
+		}
+	}
+	ok := func(r *http.Request) (interface{}, error) {
+		a.record(r, nil)
+		return nil, nil
+	}
+
+	routes := map[string]testRoute{
+		"POST /orders": func(r *http.Request) (interface{}, error) {
+			a.record(r, nil)
+			if a.beforePlace != nil {
+				a.beforePlace()
+			}
+			return models.Order{ID: 1, Market: "BTC-PERP", Status: models.Open}, nil
+		},
+		"GET /orders/1": func(r *http.Request) (interface{}, error) {
+			return models.Order{ID: 1, Market: "BTC-PERP", Status: models.Open}, nil
+		},
+		"GET /fills": func(r *http.Request) (interface{}, error) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			orderID, _ := strconv.ParseInt(r.URL.Query().Get("orderId"), 10, 64)
+			return a.fills[orderID], nil
+		},
+		"POST /conditional_orders": leg,
+		"DELETE /orders/1":         ok,
+	}
+	for id := int64(11); id <= 22; id++ {
+		routes["GET /conditional_orders/"+strconv.FormatInt(id, 10)+"/triggers"] = triggers(id)
+		routes["POST /conditional_orders/"+strconv.FormatInt(id, 10)+"/modify"] = leg
+		routes["DELETE /conditional_orders/"+strconv.FormatInt(id, 10)] = ok
+	}
+	return routes
+}
+
+func TestBracketManager(t *testing.T) {
+	fake := &bracketTestAPI{nextLegID: 10, triggers: map[int64][]models.Trigger{}}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	connC := make(chan *websocket.Conn, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe {
+			connC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := NewFileBracketStore(filepath.Join(t.TempDir(), "brackets.json"))
+	manager := NewBracketManager(ftx, WithBracketStore(store), WithBracketReconcileInterval(0))
+	require.NoError(t, manager.Start(ctx))
+
+	_, err := manager.PlaceBracket(&BracketPayload{
+		Entry:      models.PlaceOrderPayload{Market: "BTC-PERP", Side: models.Buy, Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.NewFromInt(2)},
+		TakeProfit: decimal.NewFromInt(8000),
+		StopLoss:   decimal.NewFromInt(8500),
+	})
+	require.Error(t, err)
+
+	bracket, err := manager.PlaceBracket(&BracketPayload{
+		Entry:      models.PlaceOrderPayload{Market: "BTC-PERP", Side: models.Buy, Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.NewFromInt(2)},
+		TakeProfit: decimal.NewFromInt(9500),
+		StopLoss:   decimal.NewFromInt(8500),
+	})
+	require.NoError(t, err)
+	require.Equal(t, BracketPending, bracket.Status)
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-connC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fills subscription")
+	}
+	writeFill := func(id, orderID int64, side models.Side, size int64) {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"channel": models.FillsChannel,
+			"type":    models.Update,
+			"data":    map[string]interface{}{"id": id, "orderId": orderID, "market": "BTC-PERP", "side": side, "price": 9000, "size": size},
+		}))
+	}
+	waitBracket := func(check func(b *Bracket) bool) *Bracket {
+		var b *Bracket
+		require.Eventually(t, func() bool {
+			b, _ = manager.Get(bracket.ID)
+			return check(b)
+		}, 2*time.Second, 10*time.Millisecond)
+		return b
+	}
+
+	// a partial fill of the entry places both legs
+	writeFill(100, 1, models.Buy, 1)
+	b := waitBracket(func(b *Bracket) bool { return b.Status == BracketActive })
+	require.EqualValues(t, 11, b.TakeProfitID)
+	require.EqualValues(t, 12, b.StopLossID)
+	require.True(t, b.TakeProfitSize.Equal(decimal.NewFromInt(1)))
+
+	stored, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	require.EqualValues(t, 11, stored[0].TakeProfitID)
+
+	// the rest of the entry resizes them, a modified trigger order gets a new ID
+	writeFill(101, 1, models.Buy, 1)
+	writeFill(101, 1, models.Buy, 1)
+	b = waitBracket(func(b *Bracket) bool { return b.StopLossSize.Equal(decimal.NewFromInt(2)) })
+	require.EqualValues(t, 13, b.TakeProfitID)
+	require.EqualValues(t, 14, b.StopLossID)
+	require.True(t, b.FilledSize().Equal(decimal.NewFromInt(2)))
+
+	// a fill of another order is checked with REST without blocking the manager
+	gate := make(chan struct{})
+	fake.mu.Lock()
+	fake.triggersGate = gate
+	fake.mu.Unlock()
+	writeFill(103, 99, models.Sell, 1)
+	time.Sleep(50 * time.Millisecond)
+	gotC := make(chan struct{})
+	go func() {
+		manager.Get(bracket.ID)
+		close(gotC)
+	}()
+	select {
+	case <-gotC:
+	case <-time.After(time.Second):
+		t.Fatal("manager is locked by a trigger lookup")
+	}
+	fake.mu.Lock()
+	fake.triggersGate = nil
+	fake.mu.Unlock()
+	close(gate)
+
+	// the take profit triggers order 31, which fills the whole position
+	fake.mu.Lock()
+	fake.triggers[13] = []models.Trigger{{OrderID: 31}}
+	fake.mu.Unlock()
+	writeFill(102, 31, models.Sell, 2)
+	b = waitBracket(func(b *Bracket) bool { return b.Status == BracketClosed })
+	require.EqualValues(t, 13, b.TriggeredID)
+	require.True(t, b.ExitedSize().Equal(decimal.NewFromInt(2)))
+
+	require.Equal(t, []string{
+		"POST /orders",
+		"POST /conditional_orders",
+		"POST /conditional_orders",
+		"POST /conditional_orders/11/modify",
+		"POST /conditional_orders/12/modify",
+		"DELETE /orders/1",
+		"DELETE /conditional_orders/14",
+	}, fake.recorded())
+
+	stored, err = store.Load()
+	require.NoError(t, err)
+	require.Empty(t, stored)
+}
+
+func TestBracketManager_Resume(t *testing.T) {
+	fake := &bracketTestAPI{
+		nextLegID: 20,
+		triggers:  map[int64][]models.Trigger{},
+		fills: map[int64][]models.Fill{
+			// the second fill happened while the manager was down
+			1: {{ID: 100, OrderID: 1, Size: decimal.NewFromInt(1)}, {ID: 101, OrderID: 1, Size: decimal.NewFromInt(1)}},
+		},
+	}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	store := NewFileBracketStore(filepath.Join(t.TempDir(), "brackets.json"))
+	require.NoError(t, store.Save([]*Bracket{{
+		ID:             1,
+		Market:         "BTC-PERP",
+		Side:           models.Buy,
+		Status:         BracketActive,
+		EntryOrderID:   1,
+		EntryFills:     map[int64]decimal.Decimal{100: decimal.NewFromInt(1)},
+		TakeProfit:     decimal.NewFromInt(9500),
+		StopLoss:       decimal.NewFromInt(8500),
+		TakeProfitID:   11,
+		TakeProfitSize: decimal.NewFromInt(1),
+		StopLossID:     12,
+		StopLossSize:   decimal.NewFromInt(1),
+	}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewBracketManager(ftx, WithBracketStore(store), WithBracketReconcileInterval(0))
+	require.NoError(t, manager.Start(ctx))
+
+	b, ok := manager.Get(1)
+	require.True(t, ok)
+	require.Equal(t, BracketActive, b.Status)
+	require.True(t, b.FilledSize().Equal(decimal.NewFromInt(2)))
+	require.EqualValues(t, 21, b.TakeProfitID)
+	require.EqualValues(t, 22, b.StopLossID)
+	require.Equal(t, []string{
+		"POST /conditional_orders/11/modify",
+		"POST /conditional_orders/12/modify",
+	}, fake.recorded())
+}
+
+func TestBracketManager_EarlyFill(t *testing.T) {
+	fake := &bracketTestAPI{nextLegID: 10, triggers: map[int64][]models.Trigger{}}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	connC := make(chan *websocket.Conn, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe {
+			connC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewBracketManager(ftx, WithBracketReconcileInterval(0))
+	require.NoError(t, manager.Start(ctx))
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-connC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fills subscription")
+	}
+
+	// the entry fills before its placement is answered
+	fake.beforePlace = func() {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"channel": models.FillsChannel,
+			"type":    models.Update,
+			"data":    map[string]interface{}{"id": 100, "orderId": 1, "market": "BTC-PERP", "side": models.Buy, "price": 9000, "size": 2},
+		}))
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	bracket, err := manager.PlaceBracket(&BracketPayload{
+		Entry:      models.PlaceOrderPayload{Market: "BTC-PERP", Side: models.Buy, Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.NewFromInt(2)},
+		TakeProfit: decimal.NewFromInt(9500),
+		StopLoss:   decimal.NewFromInt(8500),
+	})
+	require.NoError(t, err)
+	require.True(t, bracket.FilledSize().Equal(decimal.NewFromInt(2)))
+
+	var b *Bracket
+	require.Eventually(t, func() bool {
+		b, _ = manager.Get(bracket.ID)
+		return b.Status == BracketActive && b.StopLossID != 0
+	}, 2*time.Second, 10*time.Millisecond)
+	require.True(t, b.TakeProfitSize.Equal(decimal.NewFromInt(2)))
+}
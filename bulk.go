@@ -0,0 +1,201 @@
+package goftx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	defaultBulkConcurrency = 8
+	// stays below the order rate limits of FTX accounts
+	defaultBulkRate  = 30
+	defaultBulkBurst = 30
+)
+
+// WithBulkConcurrency sets how many requests of PlaceOrders, CancelOrders and ModifyOrders are sent at once.
+func WithBulkConcurrency(concurrency int) Option {
+	return func(c *Client) {
+		c.bulkConcurrency = concurrency
+	}
+}
+
+// WithBulkRateLimit sets the requests per second and the burst shared by the bulk requests of the client.
+func WithBulkRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) {
+		c.bulkLimiter = newRateLimiter(perSecond, burst)
+	}
+}
+
+// OrderResult is the result of one item of PlaceOrders or ModifyOrders.
+type OrderResult struct {
+	Order *models.Order
+	// Err is a *BulkOrderError.
+	Err error
+}
+
+// BulkOrderError is the error of one item of a bulk request.
+type BulkOrderError struct {
+	Index int
+	// OrderID is set for cancellations and modifications.
+	OrderID int64
+	// NotSent is set when the request was not sent since ctx was done.
+	NotSent bool
+	Err     error
+}
+
+func (e *BulkOrderError) Error() string {
+	if e.OrderID != 0 {
+		return fmt.Sprintf("item %d, order %d: %v", e.Index, e.OrderID, e.Err)
+	}
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BulkOrderError) Unwrap() error {
+	return e.Err
+}
+
+// PlaceOrders places orders concurrently within the bulk rate limit.
+// Results are in the order of payloads, items which are not sent before ctx is done fail with NotSent.
+func (o *Orders) PlaceOrders(ctx context.Context, payloads []models.PlaceOrderPayload) []OrderResult {
+	results := make([]OrderResult, len(payloads))
+	o.bulk(ctx, len(payloads), false, func(i int) {
+		order, err := o.PlaceOrder(&payloads[i])
+		results[i] = OrderResult{Order: order}
+		if err != nil {
+			results[i].Err = &BulkOrderError{Index: i, Err: err}
+		}
+	}, func(i int, err error) {
+		results[i].Err = &BulkOrderError{Index: i, NotSent: true, Err: err}
+	})
+	return results
+}
+
+// ModifyOrders modifies orders concurrently within the bulk rate limit, results are in the order of modifications.
+func (o *Orders) ModifyOrders(ctx context.Context, modifications []models.OrderModification) []OrderResult {
+	results := make([]OrderResult, len(modifications))
+	o.bulk(ctx, len(modifications), false, func(i int) {
+		order, err := o.ModifyOrder(&modifications[i].Payload, modifications[i].OrderID)
+		results[i] = OrderResult{Order: order}
+		if err != nil {
+			results[i].Err = &BulkOrderError{Index: i, OrderID: modifications[i].OrderID, Err: err}
+		}
+	}, func(i int, err error) {
+		results[i].Err = &BulkOrderError{Index: i, OrderID: modifications[i].OrderID, NotSent: true, Err: err}
+	})
+	return results
+}
+
+// CancelOrders cancels orders concurrently and returns an error or nil per order ID.
+// Cancellations take the rate limit ahead of waiting placements and modifications.
+func (o *Orders) CancelOrders(ctx context.Context, orderIDs []int64) []error {
+	errs := make([]error, len(orderIDs))
+	o.bulk(ctx, len(orderIDs), true, func(i int) {
+		err := o.CancelOrder(orderIDs[i])
+		if err != nil {
+			errs[i] = &BulkOrderError{Index: i, OrderID: orderIDs[i], Err: err}
+		}
+	}, func(i int, err error) {
+		errs[i] = &BulkOrderError{Index: i, OrderID: orderIDs[i], NotSent: true, Err: err}
+	})
+	return errs
+}
+
+// bulk runs n items with the concurrency of the client, every item waits for the rate limit.
+func (o *Orders) bulk(ctx context.Context, n int, priority bool, run func(i int), skip func(i int, err error)) {
+	concurrency := o.client.bulkConcurrency
+	if concurrency <= 0 || concurrency > n {
+		concurrency = n
+	}
+
+	itemsC := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range itemsC {
+				err := o.client.bulkLimiter.wait(ctx, priority)
+				if err != nil {
+					skip(i, errors.WithStack(err))
+					continue
+				}
+				run(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		itemsC <- i
+	}
+	close(itemsC)
+	wg.Wait()
+}
+
+// rateLimiter is a token bucket, waiters with priority take tokens ahead of the others.
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	priority int
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) wait(ctx context.Context, priority bool) error {
+	if err := ctx.Err(); err != nil || l == nil || l.rate <= 0 {
+		return err
+	}
+
+	l.mu.Lock()
+	if priority {
+		l.priority++
+		defer func() {
+			l.mu.Lock()
+			l.priority--
+			l.mu.Unlock()
+		}()
+	}
+	for {
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		if l.tokens >= 1 && (priority || l.priority == 0) {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		if delay <= 0 {
+			// a token is free but reserved for waiting cancellations
+			delay = time.Duration(float64(time.Second) / l.rate)
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		l.mu.Lock()
+	}
+}
@@ -0,0 +1,140 @@
+package goftx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestOrders_PlaceOrders(t *testing.T) {
+	var inFlight, maxInFlight int32
+	api := newTestAPI(t, map[string]testRoute{
+		"POST /orders": func(r *http.Request) (interface{}, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+
+			var payload models.PlaceOrderPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				return nil, err
+			}
+			// later items are answered first
+			time.Sleep(time.Duration(10-payload.Size.IntPart()) * 5 * time.Millisecond)
+			if payload.Size.IntPart() == 3 {
+				return nil, errors.New("Size too small")
+			}
+			return models.Order{ID: payload.Size.IntPart(), Size: payload.Size}, nil
+		},
+	})
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"), WithBulkConcurrency(4))
+	ftx.apiURL = api.URL
+
+	var payloads []models.PlaceOrderPayload
+	for i := 1; i <= 10; i++ {
+		payloads = append(payloads, models.PlaceOrderPayload{Market: "BTC-PERP", Side: models.Buy, Type: models.LimitOrder, Size: decimal.NewFromInt(int64(i))})
+	}
+	results := ftx.Orders.PlaceOrders(context.Background(), payloads)
+	require.Len(t, results, 10)
+	for i, result := range results {
+		if i == 2 {
+			var bulkErr *BulkOrderError
+			require.True(t, errors.As(result.Err, &bulkErr))
+			require.Equal(t, 2, bulkErr.Index)
+			require.False(t, bulkErr.NotSent)
+			require.True(t, strings.Contains(bulkErr.Error(), "Size too small"))
+			continue
+		}
+		require.NoError(t, result.Err)
+		require.EqualValues(t, i+1, result.Order.ID)
+	}
+	require.True(t, atomic.LoadInt32(&maxInFlight) <= 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = ftx.Orders.PlaceOrders(ctx, payloads[:2])
+	for _, result := range results {
+		var bulkErr *BulkOrderError
+		require.True(t, errors.As(result.Err, &bulkErr))
+		require.True(t, bulkErr.NotSent)
+		require.True(t, errors.Is(result.Err, context.Canceled))
+	}
+}
+
+func TestOrders_CancelAndModifyOrders(t *testing.T) {
+	api := newTestAPI(t, map[string]testRoute{
+		"DELETE /orders/1": func(r *http.Request) (interface{}, error) {
+			return "Order queued for cancellation", nil
+		},
+		"DELETE /orders/2": func(r *http.Request) (interface{}, error) {
+			return nil, errors.New("Order already closed")
+		},
+		"POST /orders/1/modify": func(r *http.Request) (interface{}, error) {
+			return models.Order{ID: 11}, nil
+		},
+	})
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"), WithBulkRateLimit(20, 1))
+	ftx.apiURL = api.URL
+
+	start := time.Now()
+	errs := ftx.Orders.CancelOrders(context.Background(), []int64{1, 2, 1})
+	require.NoError(t, errs[0])
+	require.Error(t, errs[1])
+	require.NoError(t, errs[2])
+	var bulkErr *BulkOrderError
+	require.True(t, errors.As(errs[1], &bulkErr))
+	require.EqualValues(t, 2, bulkErr.OrderID)
+	// a burst of one at 20 per second
+	require.True(t, time.Since(start) >= 90*time.Millisecond)
+
+	size := decimal.NewFromInt(2)
+	results := ftx.Orders.ModifyOrders(context.Background(), []models.OrderModification{
+		{OrderID: 1, Payload: models.ModifyOrderPayload{Size: &size}},
+		{OrderID: 3, Payload: models.ModifyOrderPayload{Size: &size}},
+	})
+	require.NoError(t, results[0].Err)
+	require.EqualValues(t, 11, results[0].Order.ID)
+	require.True(t, errors.As(results[1].Err, &bulkErr))
+	require.EqualValues(t, 3, bulkErr.OrderID)
+}
+
+func TestRateLimiter_Priority(t *testing.T) {
+	limiter := newRateLimiter(10, 1)
+	require.NoError(t, limiter.wait(context.Background(), false))
+
+	orderC := make(chan string, 2)
+	go func() {
+		_ = limiter.wait(context.Background(), false)
+		orderC <- "place"
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		_ = limiter.wait(context.Background(), true)
+		orderC <- "cancel"
+	}()
+
+	require.Equal(t, "cancel", <-orderC)
+	require.Equal(t, "place", <-orderC)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, limiter.wait(ctx, false))
+}
@@ -0,0 +1,167 @@
+package goftx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+var errUnknownChannel = errors.New("unknown channel")
+
+// ChannelDecoder converts a message of a channel into its typed response.
+// Builtin channels are decoded in a single pass, custom channels get their Data
+// as a copy that may be kept after the decoder returns.
+type ChannelDecoder func(message *models.WsResponse) (interface{}, error)
+
+// ChannelDefinition describes a websocket channel.
+// A nil Decoder passes *models.WsResponse through, Args are sent with every subscription request.
+type ChannelDefinition struct {
+	Name    models.Channel
+	Private bool
+	Decoder ChannelDecoder
+	Args    map[string]interface{}
+}
+
+type channelRegistry struct {
+	mu       sync.RWMutex
+	channels map[models.Channel]ChannelDefinition
+}
+
+func newChannelRegistry() *channelRegistry {
+	r := &channelRegistry{
+		channels: make(map[models.Channel]ChannelDefinition),
+	}
+
+	builtins := []ChannelDefinition{
+		{
+			Name: models.TickerChannel,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToTickerResponse()
+			},
+		},
+		{
+			Name: models.TradesChannel,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToTradesResponse()
+			},
+		},
+		{
+			Name: models.OrderBookChannel,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToOrderBookResponse()
+			},
+		},
+		{
+			Name: models.GroupedOrderBookChannel,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToGroupedOrderBookResponse()
+			},
+		},
+		{
+			Name:    models.OrdersChannel,
+			Private: true,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToOrderResponse()
+			},
+		},
+		{
+			Name:    models.FillsChannel,
+			Private: true,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToFillResponse()
+			},
+		},
+		{
+			Name: models.MarketsChannel,
+			Decoder: func(message *models.WsResponse) (interface{}, error) {
+				return message.MapToMarketsResponse()
+			},
+		},
+	}
+	for _, definition := range builtins {
+		r.channels[definition.Name] = definition
+	}
+
+	return r
+}
+
+func (r *channelRegistry) register(definition ChannelDefinition) error {
+	if definition.Name == "" {
+		return errors.New("channel name is missing")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.channels[definition.Name]; ok {
+		return errors.Errorf("channel %v is already registered", definition.Name)
+	}
+	r.channels[definition.Name] = definition
+
+	return nil
+}
+
+func (r *channelRegistry) get(channel models.Channel) (ChannelDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definition, ok := r.channels[channel]
+	return definition, ok
+}
+
+func (r *channelRegistry) isPrivate(req models.WSRequest) bool {
+	if definition, ok := r.get(req.Channel); ok {
+		return definition.Private
+	}
+	return req.IsPrivateChannel()
+}
+
+func (r *channelRegistry) decode(message *models.WsResponse) (interface{}, error) {
+	definition, ok := r.get(message.Channel)
+	if !ok {
+		return nil, errUnknownChannel
+	}
+	if definition.Decoder == nil {
+		return message, nil
+	}
+
+	return definition.Decoder(message)
+}
+
+// RegisterChannel adds a channel that is not supported by the library out of the box.
+// Messages of the channel are decoded with its decoder and delivered by Subscribe.
+func (s *Stream) RegisterChannel(definition ChannelDefinition) error {
+	return s.channels.register(definition)
+}
+
+// Subscribe subscribes to any registered channel, a channel without markets is subscribed once.
+func (s *Stream) Subscribe(ctx context.Context, channel models.Channel, symbols ...string) (chan interface{}, error) {
+	definition, ok := s.channels.get(channel)
+	if !ok {
+		return nil, errors.Errorf("channel %v is not registered", channel)
+	}
+
+	if len(symbols) == 0 {
+		symbols = []string{""}
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: channel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+			Args:    definition.Args,
+		})
+	}
+
+	ctx, eventsC, err := s.serve(ctx, requests...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardValues(ctx, eventsC), nil
+}
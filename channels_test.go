@@ -0,0 +1,78 @@
+package goftx
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+type testPayment struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestStream_RegisterChannel(t *testing.T) {
+	const payChannel = models.Channel("ftxpay")
+
+	loginC := make(chan models.WSRequest, 1)
+	subscribeC := make(chan models.WSRequest, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		switch req.Op {
+		case models.Login:
+			loginC <- req
+		case models.Subscribe:
+			subscribeC <- req
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": req.Channel,
+				"type":    models.Update,
+				"data":    map[string]interface{}{"id": 42, "status": "paid"},
+			})
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+
+	err := ftx.Stream.RegisterChannel(ChannelDefinition{
+		Name:    payChannel,
+		Private: true,
+		Args:    map[string]interface{}{"version": "v2"},
+		Decoder: func(message *models.WsResponse) (interface{}, error) {
+			payment := &testPayment{}
+			err := json.Unmarshal(message.Data, payment)
+			return payment, err
+		},
+	})
+	require.NoError(t, err)
+	require.Error(t, ftx.Stream.RegisterChannel(ChannelDefinition{Name: models.TickerChannel}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data, err := ftx.Stream.Subscribe(ctx, payChannel)
+	require.NoError(t, err)
+
+	select {
+	case <-loginC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("private channel is not logged in")
+	}
+
+	require.Equal(t, "v2", (<-subscribeC).Args["version"])
+
+	select {
+	case msg := <-data:
+		require.Equal(t, &testPayment{ID: 42, Status: "paid"}, msg)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no payment received")
+	}
+
+	_, err = ftx.Stream.Subscribe(ctx, models.Channel("unknown"))
+	require.Error(t, err)
+}
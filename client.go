@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"sync"
 	"time"
@@ -41,6 +43,67 @@ func WithFTXUS() Option {
 	}
 }
 
+// WithWebsocketDialer sets the dialer of websocket connections, finer websocket options are applied on top of it
+// whatever their order. The dialer is copied, a nil dialer keeps websocket.DefaultDialer.
+func WithWebsocketDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) {
+		if dialer == nil {
+			dialer = websocket.DefaultDialer
+		}
+		d := *dialer
+		c.wsDialer = &d
+	}
+}
+
+func WithWebsocketProxy(proxyURL *url.URL) Option {
+	return func(c *Client) {
+		c.wsDialerOpts = append(c.wsDialerOpts, func(d *websocket.Dialer) {
+			d.Proxy = http.ProxyURL(proxyURL)
+		})
+	}
+}
+
+func WithWebsocketHandshakeTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.wsDialerOpts = append(c.wsDialerOpts, func(d *websocket.Dialer) {
+			d.HandshakeTimeout = timeout
+		})
+	}
+}
+
+// WithWebsocketCompression negotiates permessage-deflate compression with the server.
+func WithWebsocketCompression(enabled bool) Option {
+	return func(c *Client) {
+		c.wsDialerOpts = append(c.wsDialerOpts, func(d *websocket.Dialer) {
+			d.EnableCompression = enabled
+		})
+	}
+}
+
+func WithWebsocketBufferSizes(readBufferSize, writeBufferSize int) Option {
+	return func(c *Client) {
+		c.wsDialerOpts = append(c.wsDialerOpts, func(d *websocket.Dialer) {
+			d.ReadBufferSize = readBufferSize
+			d.WriteBufferSize = writeBufferSize
+		})
+	}
+}
+
+func WithWebsocketTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.wsDialerOpts = append(c.wsDialerOpts, func(d *websocket.Dialer) {
+			d.TLSClientConfig = config
+		})
+	}
+}
+
+// WithWebsocketHeaders sets extra headers of the websocket handshake request.
+func WithWebsocketHeaders(header http.Header) Option {
+	return func(c *Client) {
+		c.wsHeader = header.Clone()
+	}
+}
+
 func WithAuth(key, secret string, subAccount ...string) Option {
 	return func(c *Client) {
 		c.apiKey = key
@@ -62,6 +125,14 @@ type Client struct {
 	serverTimeDiff time.Duration
 	isFtxUS        bool
 	apiURL         string
+	wsDialer       *websocket.Dialer
+	// wsDialerOpts are applied to wsDialer after all options, so they are not lost to WithWebsocketDialer.
+	wsDialerOpts []func(d *websocket.Dialer)
+	wsHeader     http.Header
+	validator    *OrderValidator
+	// bulkLimiter is shared by the bulk order requests.
+	bulkLimiter     *rateLimiter
+	bulkConcurrency int
 	SubAccounts
 	Markets
 	Account
@@ -75,13 +146,20 @@ type Client struct {
 }
 
 func New(opts ...Option) *Client {
+	defaultDialer := *websocket.DefaultDialer
 	client := &Client{
-		client: http.DefaultClient,
+		client:          http.DefaultClient,
+		wsDialer:        &defaultDialer,
+		bulkLimiter:     newRateLimiter(defaultBulkRate, defaultBulkBurst),
+		bulkConcurrency: defaultBulkConcurrency,
 	}
 
 	for _, opt := range opts {
 		opt(client)
 	}
+	for _, opt := range client.wsDialerOpts {
+		opt(client.wsDialer)
+	}
 
 	domain := "com"
 	if client.isFtxUS {
@@ -104,10 +182,15 @@ func New(opts ...Option) *Client {
 		subAccount:             client.subAccount,
 		mu:                     &sync.Mutex{},
 		url:                    fmt.Sprintf(wsUrlFormat, domain),
-		dialer:                 websocket.DefaultDialer,
+		dialer:                 client.wsDialer,
+		header:                 client.wsHeader,
 		wsReconnectionCount:    reconnectCount,
 		wsReconnectionInterval: reconnectInterval,
 		wsTimeout:              streamTimeout,
+		pingInterval:           pingInterval,
+		channels:               newChannelRegistry(),
+		lifecycle:              newLifecycle(),
+		metrics:                NewMemoryMetrics(),
 	}
 
 	return client
@@ -152,7 +235,8 @@ func (c *Client) prepareRequest(request Request) (*http.Request, error) {
 
 	if request.Auth {
 		nonce := strconv.FormatInt(time.Now().UTC().Add(c.serverTimeDiff).Unix()*1000, 10)
-		payload := nonce + req.Method + req.URL.Path
+		// the path is signed as it is sent, with escaped path parameters
+		payload := nonce + req.Method + req.URL.EscapedPath()
 		if req.URL.RawQuery != "" {
 			payload += "?" + req.URL.RawQuery
 		}
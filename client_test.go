@@ -1,10 +1,15 @@
 package goftx
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,3 +34,76 @@ func TestClient_Ping(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestClient_WebsocketOptions(t *testing.T) {
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	proxyURL, err := url.Parse("http://proxy.local:3128")
+	require.NoError(t, err)
+
+	dialer := &websocket.Dialer{HandshakeTimeout: time.Minute}
+	ftx := newTestClient(ts,
+		WithWebsocketDialer(dialer),
+		WithWebsocketHandshakeTimeout(5*time.Second),
+		WithWebsocketCompression(true),
+		WithWebsocketBufferSizes(1<<16, 1<<12),
+		WithWebsocketHeaders(http.Header{"X-Egress": []string{"trading"}}),
+	)
+	require.Equal(t, time.Minute, dialer.HandshakeTimeout)
+	require.Equal(t, 5*time.Second, ftx.Stream.dialer.HandshakeTimeout)
+	require.Equal(t, 1<<16, ftx.Stream.dialer.ReadBufferSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	headers := ts.handshakeHeaders()
+	require.Len(t, headers, 1)
+	require.Equal(t, "trading", headers[0].Get("X-Egress"))
+	require.Contains(t, headers[0].Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+	proxied := New(WithWebsocketProxy(proxyURL))
+	proxy, err := proxied.Stream.dialer.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "ftx.com"}})
+	require.NoError(t, err)
+	require.Equal(t, proxyURL, proxy)
+
+	canceled, cancelDial := context.WithCancel(context.Background())
+	cancelDial()
+	_, err = ftx.Stream.SubscribeToTickers(canceled, "BTC-PERP")
+	require.Error(t, err)
+}
+
+func TestClient_WebsocketOptionsBeforeDialer(t *testing.T) {
+	proxyURL, err := url.Parse("http://proxy.local:3128")
+	require.NoError(t, err)
+	tlsConfig := &tls.Config{ServerName: "ftx.com"}
+
+	dialer := &websocket.Dialer{HandshakeTimeout: time.Minute}
+	ftx := New(
+		WithWebsocketProxy(proxyURL),
+		WithWebsocketHandshakeTimeout(5*time.Second),
+		WithWebsocketCompression(true),
+		WithWebsocketBufferSizes(1<<16, 1<<12),
+		WithWebsocketTLSConfig(tlsConfig),
+		WithWebsocketDialer(dialer),
+	)
+	require.Equal(t, time.Minute, dialer.HandshakeTimeout)
+	require.Nil(t, dialer.Proxy)
+
+	require.Equal(t, 5*time.Second, ftx.Stream.dialer.HandshakeTimeout)
+	require.True(t, ftx.Stream.dialer.EnableCompression)
+	require.Equal(t, 1<<16, ftx.Stream.dialer.ReadBufferSize)
+	require.Equal(t, 1<<12, ftx.Stream.dialer.WriteBufferSize)
+	require.Equal(t, tlsConfig, ftx.Stream.dialer.TLSClientConfig)
+	proxy, err := ftx.Stream.dialer.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "ftx.com"}})
+	require.NoError(t, err)
+	require.Equal(t, proxyURL, proxy)
+
+	// a nil dialer keeps the default one, which is not modified
+	ftx = New(WithWebsocketDialer(nil), WithWebsocketHandshakeTimeout(5*time.Second))
+	require.Equal(t, 5*time.Second, ftx.Stream.dialer.HandshakeTimeout)
+	require.Equal(t, 45*time.Second, websocket.DefaultDialer.HandshakeTimeout)
+}
@@ -0,0 +1,120 @@
+package goftx
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	// ClientTagSeparator separates the tag of a client ID from the rest of it, as in "tag-42".
+	ClientTagSeparator = "-"
+
+	clientIDTimeWidth     = 9
+	clientIDSequenceWidth = 6
+	// clientIDSequenceMax is the largest sequence that fits its width, 36^6 - 1.
+	clientIDSequenceMax = 2176782335
+)
+
+// ClientIDGenerator generates unique client IDs of orders tagged by tag.
+type ClientIDGenerator interface {
+	Next(tag string) models.ClientID
+}
+
+// ClientIDSequence generates client IDs as tag-time-sequence, where time is the unix time in milliseconds
+// and sequence is a counter, both in fixed width base 36. IDs of a tag sort by the order of generation,
+// the time never goes back even if the clock does. A sequence that outgrows its width starts over at 1
+// in the next millisecond, so IDs stay unique and sorted.
+type ClientIDSequence struct {
+	mu       sync.Mutex
+	last     int64
+	sequence uint64
+	now      func() time.Time
+}
+
+func NewClientIDSequence() *ClientIDSequence {
+	return &ClientIDSequence{now: time.Now}
+}
+
+func (g *ClientIDSequence) Next(tag string) models.ClientID {
+	g.mu.Lock()
+	millis := g.now().UnixNano() / int64(time.Millisecond)
+	if millis < g.last {
+		millis = g.last
+	}
+	g.sequence++
+	if g.sequence > clientIDSequenceMax {
+		g.sequence = 1
+		millis++
+	}
+	g.last = millis
+	sequence := g.sequence
+	g.mu.Unlock()
+
+	timePart, err := padBase36(uint64(millis), clientIDTimeWidth)
+	if err != nil {
+		// 9 digits of milliseconds last until the year 5138
+		panic(err)
+	}
+	sequencePart, err := padBase36(sequence, clientIDSequenceWidth)
+	if err != nil {
+		panic(err)
+	}
+
+	id := timePart + ClientTagSeparator + sequencePart
+	if tag != "" {
+		id = tag + ClientTagSeparator + id
+	}
+	return models.ClientID(id)
+}
+
+// padBase36 formats value in base 36 padded with zeros to width, values wider than width are an error.
+func padBase36(value uint64, width int) (string, error) {
+	s := strconv.FormatUint(value, 36)
+	if len(s) > width {
+		return "", errors.Errorf("%v does not fit %v base 36 digits", value, width)
+	}
+	return strings.Repeat("0", width-len(s)) + s, nil
+}
+
+// ClientIDInfo is what DecodeClientID recovers from a client ID.
+type ClientIDInfo struct {
+	Tag string
+	// Time is set for IDs of ClientIDSequence.
+	Time     time.Time
+	Sequence uint64
+}
+
+// DecodeClientID splits IDs of ClientIDSequence and IDs like tag-42 into the tag and the rest,
+// any other ID is a tag by itself.
+func DecodeClientID(id models.ClientID) ClientIDInfo {
+	parts := strings.Split(string(id), ClientTagSeparator)
+	n := len(parts)
+
+	if n >= 2 && len(parts[n-2]) == clientIDTimeWidth && len(parts[n-1]) == clientIDSequenceWidth {
+		millis, errTime := strconv.ParseUint(parts[n-2], 36, 64)
+		sequence, errSequence := strconv.ParseUint(parts[n-1], 36, 64)
+		if errTime == nil && errSequence == nil {
+			return ClientIDInfo{
+				Tag:      strings.Join(parts[:n-2], ClientTagSeparator),
+				Time:     time.Unix(0, int64(millis)*int64(time.Millisecond)),
+				Sequence: sequence,
+			}
+		}
+	}
+	if n >= 2 {
+		sequence, err := strconv.ParseUint(parts[n-1], 10, 64)
+		if err == nil {
+			return ClientIDInfo{
+				Tag:      strings.Join(parts[:n-1], ClientTagSeparator),
+				Sequence: sequence,
+			}
+		}
+	}
+	return ClientIDInfo{Tag: string(id)}
+}
@@ -0,0 +1,77 @@
+package goftx
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestClientIDSequence(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	generator := NewClientIDSequence()
+	generator.now = func() time.Time { return now }
+
+	var ids []string
+	for i := 0; i < 40; i++ {
+		ids = append(ids, string(generator.Next("mm")))
+		if i == 20 {
+			// the clock goes back
+			now = now.Add(-time.Minute)
+		}
+		if i == 30 {
+			now = now.Add(time.Hour)
+		}
+	}
+	require.True(t, sort.StringsAreSorted(ids))
+
+	info := DecodeClientID(models.ClientID(ids[0]))
+	require.Equal(t, "mm", info.Tag)
+	require.EqualValues(t, 1, info.Sequence)
+	require.True(t, info.Time.Equal(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)))
+
+	untagged := generator.Next("")
+	require.Equal(t, "", DecodeClientID(untagged).Tag)
+	require.EqualValues(t, 41, DecodeClientID(untagged).Sequence)
+}
+
+func TestClientIDSequence_Overflow(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	generator := NewClientIDSequence()
+	generator.now = func() time.Time { return now }
+	generator.sequence = clientIDSequenceMax - 1
+
+	ids := []string{string(generator.Next("mm")), string(generator.Next("mm"))}
+	require.True(t, sort.StringsAreSorted(ids))
+	require.Len(t, ids[1], len(ids[0]))
+
+	last := DecodeClientID(models.ClientID(ids[0]))
+	require.EqualValues(t, clientIDSequenceMax, last.Sequence)
+	first := DecodeClientID(models.ClientID(ids[1]))
+	require.EqualValues(t, 1, first.Sequence)
+	require.True(t, first.Time.Equal(now.Add(time.Millisecond)))
+
+	_, err := padBase36(36*36, 2)
+	require.Error(t, err)
+}
+
+func TestDecodeClientID(t *testing.T) {
+	for _, tt := range []struct {
+		id       models.ClientID
+		tag      string
+		sequence uint64
+	}{
+		{"mm-btc-0klqj5hc0-00000a", "mm-btc", 10},
+		{"twap-42", "twap", 42},
+		{"hedge", "hedge", 0},
+		{"hedge-x", "hedge-x", 0},
+		{"", "", 0},
+	} {
+		info := DecodeClientID(tt.id)
+		require.Equal(t, tt.tag, info.Tag, tt.id)
+		require.Equal(t, tt.sequence, info.Sequence, tt.id)
+	}
+}
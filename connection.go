@@ -0,0 +1,207 @@
+package goftx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// connection holds the websocket connection of a single serve loop.
+// The underlying conn is replaced on reconnect and written to from both
+// the read loop and the heartbeat loop, so every access goes through it.
+type connection struct {
+	mu         sync.Mutex
+	writeMu    sync.Mutex
+	conn       *websocket.Conn
+	requests   []models.WSRequest
+	pingSentAt time.Time
+	lastSeen   map[string]time.Time
+}
+
+func newConnection(conn *websocket.Conn, requests []models.WSRequest) *connection {
+	c := &connection{requests: append([]models.WSRequest(nil), requests...)}
+	c.reset(conn)
+	return c
+}
+
+func (c *connection) get() *websocket.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn
+}
+
+func (c *connection) reset(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.conn = conn
+	c.pingSentAt = time.Time{}
+	c.lastSeen = make(map[string]time.Time, len(c.requests))
+	for _, req := range c.requests {
+		if req.Market == "" {
+			continue
+		}
+		c.lastSeen[feedKey(req.Channel, req.Market)] = now
+	}
+}
+
+func (c *connection) writeJSON(v interface{}) error {
+	conn := c.get()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return errors.WithStack(conn.WriteJSON(v))
+}
+
+func (c *connection) writeClose() error {
+	conn := c.get()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	return errors.WithStack(conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait)))
+}
+
+func (c *connection) close() error {
+	return errors.WithStack(c.get().Close())
+}
+
+// subscriptions returns the requests the connection is subscribed with, they are sent again on reconnect.
+func (c *connection) subscriptions() []models.WSRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]models.WSRequest(nil), c.requests...)
+}
+
+// add subscribes req on the running connection with subscribe and keeps it for reconnects.
+func (c *connection) add(req models.WSRequest, subscribe func(*websocket.Conn, []models.WSRequest) error) error {
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	if req.Market != "" {
+		c.lastSeen[feedKey(req.Channel, req.Market)] = time.Now()
+	}
+	conn := c.conn
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return errors.WithStack(subscribe(conn, []models.WSRequest{req}))
+}
+
+// remove unsubscribes the request of channel and market added before.
+func (c *connection) remove(channel models.Channel, market string) error {
+	c.mu.Lock()
+	var (
+		removed models.WSRequest
+		found   bool
+	)
+	for i, req := range c.requests {
+		if req.Channel == channel && req.Market == market {
+			removed, found = req, true
+			c.requests = append(c.requests[:i:i], c.requests[i+1:]...)
+			break
+		}
+	}
+	delete(c.lastSeen, feedKey(channel, market))
+	c.mu.Unlock()
+
+	if !found {
+		return nil
+	}
+	removed.Op = models.UnSubscribe
+	return c.writeJSON(removed)
+}
+
+func (c *connection) unsubscribe() error {
+	for _, req := range c.subscriptions() {
+		unsubscribe := req
+		unsubscribe.Op = models.UnSubscribe
+		err := c.writeJSON(unsubscribe)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (c *connection) ping() error {
+	c.mu.Lock()
+	c.pingSentAt = time.Now()
+	c.mu.Unlock()
+
+	return c.writeJSON(models.WSRequest{Op: models.Ping})
+}
+
+// pong returns the round-trip time of the last ping or false if no ping is in flight.
+func (c *connection) pong() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pingSentAt.IsZero() {
+		return 0, false
+	}
+	rtt := time.Since(c.pingSentAt)
+	c.pingSentAt = time.Time{}
+	return rtt, true
+}
+
+func (c *connection) touch(channel models.Channel, market string) {
+	if market == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := feedKey(channel, market)
+	if _, ok := c.lastSeen[key]; ok {
+		c.lastSeen[key] = time.Now()
+	}
+}
+
+// stale returns the subscriptions that have not received data for longer than timeout
+// and marks them as fresh, so a resubscribe is not repeated on every check.
+func (c *connection) stale(timeout time.Duration) []models.WSRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var result []models.WSRequest
+	for _, req := range c.requests {
+		key := feedKey(req.Channel, req.Market)
+		seen, ok := c.lastSeen[key]
+		if !ok || now.Sub(seen) < timeout {
+			continue
+		}
+		c.lastSeen[key] = now
+		result = append(result, req)
+	}
+	return result
+}
+
+func (c *connection) resubscribe(req models.WSRequest) error {
+	unsubscribe := req
+	unsubscribe.Op = models.UnSubscribe
+	err := c.writeJSON(unsubscribe)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.writeJSON(req)
+}
+
+func feedKey(channel models.Channel, market string) string {
+	return string(channel) + ":" + market
+}
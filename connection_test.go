@@ -0,0 +1,96 @@
+package goftx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestStream_Heartbeat(t *testing.T) {
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.Stream.SetPingInterval(50 * time.Millisecond)
+
+	rttC := make(chan time.Duration, 1)
+	ftx.Stream.SetPongHandler(func(rtt time.Duration) {
+		select {
+		case rttC <- rtt:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	select {
+	case rtt := <-rttC:
+		require.True(t, rtt > 0)
+		require.True(t, ftx.Stream.PingRTT() > 0)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no pong received")
+	}
+}
+
+func TestStream_StaleResubscribe(t *testing.T) {
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.Stream.SetStaleTimeout(200 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := ftx.Stream.SubscribeToTrades(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	var ops []models.Operation
+	timeout := time.After(2 * time.Second)
+	for len(ops) < 3 {
+		select {
+		case req := <-ts.requestsC:
+			if req.Channel == models.TradesChannel {
+				ops = append(ops, req.Op)
+			}
+		case <-timeout:
+			t.Fatalf("no resubscribe, got %v", ops)
+		}
+	}
+	require.Equal(t, []models.Operation{models.Subscribe, models.UnSubscribe, models.Subscribe}, ops)
+}
+
+func TestStream_HeartbeatDisabled(t *testing.T) {
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.Stream.SetPingInterval(0)
+	ftx.Stream.SetStaleTimeout(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	select {
+	case req := <-ts.requestsC:
+		require.Equal(t, models.Subscribe, req.Op)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no subscription")
+	}
+	select {
+	case req := <-ts.requestsC:
+		t.Fatalf("unexpected request %+v", req)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,387 @@
+package goftx
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	defaultDeadManGracePeriod   = time.Second * 30
+	defaultDeadManCheckInterval = time.Second * 5
+	deadManCancelTimeout        = time.Second * 10
+)
+
+type DeadManReason string
+
+const (
+	// DeadManStreamLost is reported when the watched stream was silent for the grace period.
+	DeadManStreamLost = DeadManReason("stream")
+	// DeadManRESTFailed is reported when the REST probe failed for the grace period.
+	DeadManRESTFailed = DeadManReason("rest")
+	DeadManShutdown   = DeadManReason("shutdown")
+	DeadManManual     = DeadManReason("manual")
+)
+
+// DeadManEvent describes a cancellation of the dead man switch, Err is set when it failed.
+type DeadManEvent struct {
+	Reason                DeadManReason
+	Markets               []string
+	ConditionalOrdersOnly bool
+	// Silence is how long the failed check did not succeed.
+	Silence time.Duration
+	Err     error
+	At      time.Time
+}
+
+type DeadManSwitchOption func(d *DeadManSwitch)
+
+// WithDeadManGracePeriod sets how long the stream or REST could be unhealthy before orders are cancelled.
+func WithDeadManGracePeriod(period time.Duration) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.gracePeriod = period
+	}
+}
+
+func WithDeadManCheckInterval(interval time.Duration) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.checkInterval = interval
+	}
+}
+
+// WithDeadManMarkets cancels the orders of the given markets only instead of every order of the account.
+func WithDeadManMarkets(markets ...string) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.markets = markets
+	}
+}
+
+// WithDeadManConditionalOrdersOnly cancels trigger orders only and leaves the rest resting.
+func WithDeadManConditionalOrdersOnly() DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.conditionalOrdersOnly = true
+	}
+}
+
+// WithDeadManHTTPClient sets the HTTP client of cancellations, by default a client with its own
+// transport is used so that cancellations do not queue behind stuck requests of the client.
+func WithDeadManHTTPClient(client *http.Client) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.httpClient = client
+	}
+}
+
+// WithDeadManStreamCheck watches a stream through lastAlive, which returns when the stream was last seen alive,
+// for example the LastMessage of a single feed from MemoryMetrics. The check is armed once lastAlive returns
+// a non-zero time. Stream.LastPong of the client by default, so it is armed by the first pong; nil disables the check.
+func WithDeadManStreamCheck(lastAlive func() time.Time) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.lastAlive = lastAlive
+	}
+}
+
+// WithDeadManProbe sets the REST health check, GetAccountInformation by default. Nil disables the check.
+func WithDeadManProbe(probe func() error) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.probe = probe
+	}
+}
+
+// WithDeadManSignals sets the signals that shut the switch down, cancelling orders. Once the orders are
+// cancelled and the hooks ran, the signal is raised again, so the process exits or other handlers get it.
+// Disabled by default.
+func WithDeadManSignals(signals ...os.Signal) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.signals = signals
+	}
+}
+
+// WithDeadManHook adds a function called after every cancellation attempt.
+func WithDeadManHook(hook func(event DeadManEvent)) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.hooks = append(d.hooks, hook)
+	}
+}
+
+// WithDeadManLogger sets the logger of the audit log. By default it goes to the debug log of the client.
+func WithDeadManLogger(logger *log.Logger) DeadManSwitchOption {
+	return func(d *DeadManSwitch) {
+		d.logger = logger
+	}
+}
+
+// DeadManSwitch cancels open orders when the stream or REST are unhealthy for longer than the grace period
+// and on Shutdown. Orders are cancelled once per outage, failed cancellations are retried every check.
+type DeadManSwitch struct {
+	client                *Client
+	gracePeriod           time.Duration
+	checkInterval         time.Duration
+	markets               []string
+	conditionalOrdersOnly bool
+	httpClient            *http.Client
+	lastAlive             func() time.Time
+	probe                 func() error
+	signals               []os.Signal
+	hooks                 []func(event DeadManEvent)
+	logger                *log.Logger
+
+	mu          sync.Mutex
+	startedAt   time.Time
+	lastREST    time.Time
+	probing     bool
+	tripped     bool
+	stopC       chan struct{}
+	stopOnce    sync.Once
+	cancelMu    sync.Mutex
+	cancelCount int
+}
+
+func NewDeadManSwitch(client *Client, opts ...DeadManSwitchOption) *DeadManSwitch {
+	d := &DeadManSwitch{
+		client:        client,
+		gracePeriod:   defaultDeadManGracePeriod,
+		checkInterval: defaultDeadManCheckInterval,
+		httpClient: &http.Client{
+			Timeout:   deadManCancelTimeout,
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		stopC:     make(chan struct{}),
+		lastAlive: client.Stream.LastPong,
+	}
+	d.probe = func() error {
+		_, err := client.Account.GetAccountInformation()
+		return err
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Start runs the checks until ctx is done or Shutdown is called.
+func (d *DeadManSwitch) Start(ctx context.Context) error {
+	if d.gracePeriod <= 0 || d.checkInterval <= 0 {
+		return errors.New("grace period and check interval must be positive")
+	}
+
+	d.mu.Lock()
+	d.startedAt = time.Now()
+	d.lastREST = d.startedAt
+	d.mu.Unlock()
+
+	var signalC chan os.Signal
+	if len(d.signals) > 0 {
+		signalC = make(chan os.Signal, 1)
+		signal.Notify(signalC, d.signals...)
+	}
+
+	go d.run(ctx, signalC)
+
+	d.logf("started, grace period %v", d.gracePeriod)
+	return nil
+}
+
+func (d *DeadManSwitch) run(ctx context.Context, signalC chan os.Signal) {
+	ticker := time.NewTicker(d.checkInterval)
+	defer ticker.Stop()
+	if signalC != nil {
+		defer signal.Stop(signalC)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.logf("stopped")
+			return
+		case <-d.stopC:
+			return
+		case sig := <-signalC:
+			d.logf("received %v", sig)
+			_ = d.Shutdown()
+			signal.Stop(signalC)
+			raise(sig)
+			return
+		case <-ticker.C:
+			d.check()
+		}
+	}
+}
+
+func (d *DeadManSwitch) check() {
+	now := time.Now()
+	d.mu.Lock()
+	if d.probe != nil && !d.probing {
+		d.probing = true
+		go d.runProbe()
+	}
+	lastREST := d.lastREST
+	startedAt := d.startedAt
+	d.mu.Unlock()
+
+	reason, silence := DeadManReason(""), time.Duration(0)
+	if d.lastAlive != nil {
+		// a stream that was never alive is not watched yet
+		lastAlive := d.lastAlive()
+		if !lastAlive.IsZero() && lastAlive.Before(startedAt) {
+			lastAlive = startedAt
+		}
+		if !lastAlive.IsZero() && now.Sub(lastAlive) > d.gracePeriod {
+			reason, silence = DeadManStreamLost, now.Sub(lastAlive)
+		}
+	}
+	if reason == "" && d.probe != nil && now.Sub(lastREST) > d.gracePeriod {
+		reason, silence = DeadManRESTFailed, now.Sub(lastREST)
+	}
+
+	d.mu.Lock()
+	if reason == "" {
+		if d.tripped {
+			d.tripped = false
+			d.mu.Unlock()
+			d.logf("healthy again, armed")
+			return
+		}
+		d.mu.Unlock()
+		return
+	}
+	if d.tripped {
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	err := d.cancel(reason, silence)
+	if err == nil {
+		d.mu.Lock()
+		d.tripped = true
+		d.mu.Unlock()
+	}
+}
+
+func (d *DeadManSwitch) runProbe() {
+	err := d.probe()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.probing = false
+	if err == nil {
+		d.lastREST = time.Now()
+	}
+}
+
+// Shutdown stops the checks and cancels orders, call it before the context of Start is cancelled
+// and the connections of the application are torn down.
+func (d *DeadManSwitch) Shutdown() error {
+	d.stopOnce.Do(func() {
+		close(d.stopC)
+		d.logf("shutting down")
+	})
+	return d.cancel(DeadManShutdown, 0)
+}
+
+// Trigger cancels orders immediately.
+func (d *DeadManSwitch) Trigger() error {
+	return d.cancel(DeadManManual, 0)
+}
+
+// Cancellations returns the number of successful cancellations.
+func (d *DeadManSwitch) Cancellations() int {
+	d.cancelMu.Lock()
+	defer d.cancelMu.Unlock()
+
+	return d.cancelCount
+}
+
+func (d *DeadManSwitch) cancel(reason DeadManReason, silence time.Duration) error {
+	d.cancelMu.Lock()
+	defer d.cancelMu.Unlock()
+
+	event := DeadManEvent{
+		Reason:                reason,
+		Markets:               d.markets,
+		ConditionalOrdersOnly: d.conditionalOrdersOnly,
+		Silence:               silence,
+		At:                    time.Now(),
+	}
+	scope := "all markets"
+	if len(d.markets) > 0 {
+		scope = strings.Join(d.markets, ",")
+	}
+	if silence > 0 {
+		d.logf("%v unhealthy for %v, cancelling orders of %v (conditional only %v)", reason, silence, scope, d.conditionalOrdersOnly)
+	} else {
+		d.logf("%v, cancelling orders of %v (conditional only %v)", reason, scope, d.conditionalOrdersOnly)
+	}
+
+	orders := d.cancelClient().Orders
+	var payloads []*models.CancelAllOrdersPayload
+	if len(d.markets) == 0 {
+		payloads = append(payloads, &models.CancelAllOrdersPayload{})
+	}
+	for i := range d.markets {
+		payloads = append(payloads, &models.CancelAllOrdersPayload{Market: &d.markets[i]})
+	}
+	for _, payload := range payloads {
+		if d.conditionalOrdersOnly {
+			conditionalOrdersOnly := true
+			payload.ConditionalOrdersOnly = &conditionalOrdersOnly
+		}
+		err := orders.CancelAllOrders(payload)
+		if err != nil && event.Err == nil {
+			event.Err = errors.WithStack(err)
+		}
+	}
+
+	if event.Err != nil {
+		d.logf("cancelling orders failed: %v", event.Err)
+	} else {
+		d.cancelCount++
+		d.logf("cancelled orders of %v", scope)
+	}
+	for _, hook := range d.hooks {
+		hook(event)
+	}
+	return event.Err
+}
+
+// cancelClient returns a copy of the client with the HTTP client of the switch.
+func (d *DeadManSwitch) cancelClient() *Client {
+	c := &Client{
+		client:         d.httpClient,
+		apiKey:         d.client.apiKey,
+		secret:         d.client.secret,
+		subAccount:     d.client.subAccount,
+		serverTimeDiff: d.client.serverTimeDiff,
+		isFtxUS:        d.client.isFtxUS,
+		apiURL:         d.client.apiURL,
+	}
+	c.Orders = Orders{client: c}
+	return c
+}
+
+func (d *DeadManSwitch) logf(format string, v ...interface{}) {
+	if d.logger == nil {
+		d.client.Stream.printf("dead man switch: "+format, v...)
+		return
+	}
+	d.logger.Printf("dead man switch: "+format, v...)
+}
+
+// raise sends sig to the process again, to be handled by whoever is left.
+func raise(sig os.Signal) {
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return
+	}
+	_ = process.Signal(sig)
+}
@@ -0,0 +1,240 @@
+package goftx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// deadManTestAPI records cancellations and answers the REST probe while healthy.
+type deadManTestAPI struct {
+	mu       sync.Mutex
+	payloads []models.CancelAllOrdersPayload
+	healthy  int32
+}
+
+func (a *deadManTestAPI) routes() map[string]testRoute {
+	return map[string]testRoute{
+		"DELETE /orders": func(r *http.Request) (interface{}, error) {
+			var payload models.CancelAllOrdersPayload
+			err := json.NewDecoder(r.Body).Decode(&payload)
+			a.mu.Lock()
+			a.payloads = append(a.payloads, payload)
+			a.mu.Unlock()
+			return "Orders queued for cancellation", err
+		},
+		"GET /account": func(r *http.Request) (interface{}, error) {
+			if atomic.LoadInt32(&a.healthy) == 0 {
+				return nil, errors.New("unavailable")
+			}
+			return models.AccountInformation{}, nil
+		},
+	}
+}
+
+func (a *deadManTestAPI) cancellations() []models.CancelAllOrdersPayload {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]models.CancelAllOrdersPayload(nil), a.payloads...)
+}
+
+func TestDeadManSwitch_StreamLost(t *testing.T) {
+	fake := &deadManTestAPI{healthy: 1}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+	ftx.Stream.SetPingInterval(20 * time.Millisecond)
+	ftx.Stream.SetReconnectionInterval(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	eventsC := make(chan DeadManEvent, 10)
+	deadman := NewDeadManSwitch(ftx,
+		WithDeadManGracePeriod(200*time.Millisecond),
+		WithDeadManCheckInterval(10*time.Millisecond),
+		WithDeadManMarkets("BTC-PERP", "ETH-PERP"),
+		WithDeadManStreamCheck(ftx.Stream.LastPong),
+		WithDeadManLogger(nil),
+		WithDeadManHook(func(event DeadManEvent) {
+			eventsC <- event
+		}),
+	)
+	require.NoError(t, deadman.Start(ctx))
+
+	// answered pings keep the switch armed
+	time.Sleep(400 * time.Millisecond)
+	require.Empty(t, fake.cancellations())
+
+	ts.Listener.Close()
+	ts.dropConnections()
+
+	select {
+	case event := <-eventsC:
+		require.Equal(t, DeadManStreamLost, event.Reason)
+		require.NoError(t, event.Err)
+		require.True(t, event.Silence > 200*time.Millisecond)
+	case <-time.After(3 * time.Second):
+		t.Fatal("orders are not cancelled")
+	}
+
+	// orders are cancelled once per outage
+	time.Sleep(100 * time.Millisecond)
+	cancellations := fake.cancellations()
+	require.Len(t, cancellations, 2)
+	require.Equal(t, "BTC-PERP", *cancellations[0].Market)
+	require.Equal(t, "ETH-PERP", *cancellations[1].Market)
+	require.Nil(t, cancellations[0].ConditionalOrdersOnly)
+	require.Equal(t, 1, deadman.Cancellations())
+}
+
+func TestDeadManSwitch_RESTFailed(t *testing.T) {
+	fake := &deadManTestAPI{}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var events int32
+	deadman := NewDeadManSwitch(ftx,
+		WithDeadManGracePeriod(100*time.Millisecond),
+		WithDeadManCheckInterval(10*time.Millisecond),
+		WithDeadManConditionalOrdersOnly(),
+		WithDeadManLogger(nil),
+		WithDeadManHook(func(event DeadManEvent) {
+			if event.Reason == DeadManRESTFailed {
+				atomic.AddInt32(&events, 1)
+			}
+		}),
+	)
+	require.NoError(t, deadman.Start(ctx))
+
+	require.Eventually(t, func() bool {
+		return len(fake.cancellations()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	cancellation := fake.cancellations()[0]
+	require.Nil(t, cancellation.Market)
+	require.True(t, *cancellation.ConditionalOrdersOnly)
+
+	// a recovery arms the switch again
+	atomic.StoreInt32(&fake.healthy, 1)
+	time.Sleep(100 * time.Millisecond)
+	atomic.StoreInt32(&fake.healthy, 0)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&events) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Len(t, fake.cancellations(), 2)
+
+	require.NoError(t, deadman.Trigger())
+	require.Len(t, fake.cancellations(), 3)
+}
+
+func TestDeadManSwitch_StreamNotAlive(t *testing.T) {
+	fake := &deadManTestAPI{healthy: 1}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deadman := NewDeadManSwitch(ftx,
+		WithDeadManGracePeriod(50*time.Millisecond),
+		WithDeadManCheckInterval(10*time.Millisecond),
+		WithDeadManStreamCheck(ftx.Stream.LastPong),
+		WithDeadManLogger(nil),
+	)
+	require.NoError(t, deadman.Start(ctx))
+
+	// the check is armed by the first pong only
+	time.Sleep(200 * time.Millisecond)
+	require.Empty(t, fake.cancellations())
+}
+
+func TestDeadManSwitch_Shutdown(t *testing.T) {
+	fake := &deadManTestAPI{healthy: 1}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reasons []DeadManReason
+	deadman := NewDeadManSwitch(ftx,
+		WithDeadManGracePeriod(50*time.Millisecond),
+		WithDeadManCheckInterval(10*time.Millisecond),
+		WithDeadManLogger(nil),
+		WithDeadManHook(func(event DeadManEvent) {
+			reasons = append(reasons, event.Reason)
+		}),
+	)
+	require.NoError(t, deadman.Start(ctx))
+
+	require.NoError(t, deadman.Shutdown())
+	require.Len(t, fake.cancellations(), 1)
+
+	// the checks are stopped, a failing probe does not cancel again
+	atomic.StoreInt32(&fake.healthy, 0)
+	time.Sleep(200 * time.Millisecond)
+	require.Len(t, fake.cancellations(), 1)
+	require.Equal(t, []DeadManReason{DeadManShutdown}, reasons)
+}
+
+func TestDeadManSwitch_Signal(t *testing.T) {
+	fake := &deadManTestAPI{healthy: 1}
+	api := newTestAPI(t, fake.routes())
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stands in for the handling of the application, which gets the signal again
+	signalC := make(chan os.Signal, 2)
+	signal.Notify(signalC, os.Interrupt)
+	defer signal.Stop(signalC)
+
+	deadman := NewDeadManSwitch(ftx, WithDeadManSignals(os.Interrupt))
+	require.NoError(t, deadman.Start(ctx))
+	raise(os.Interrupt)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-signalC:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("signal %v is not raised again", i)
+		}
+	}
+	require.Len(t, fake.cancellations(), 1)
+}
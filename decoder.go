@@ -0,0 +1,378 @@
+package goftx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// event is a decoded websocket message on its way from a read loop to subscribers.
+// Exactly one response field is set, so builtin responses are passed without interface{} boxing
+// and the type assertions it requires; only custom channels use the custom field.
+type event struct {
+	ticker  *models.TickerResponse
+	trades  *models.TradesResponse
+	book    *models.OrderBookResponse
+	grouped *models.GroupedOrderBookResponse
+	order   *models.OrderResponse
+	fill    *models.FillResponse
+	markets *models.MarketsResponse
+	custom  interface{}
+	// channel and market of the frame, set by serve.
+	channel models.Channel
+	market  string
+}
+
+func newEvent(response interface{}) event {
+	switch r := response.(type) {
+	case *models.TickerResponse:
+		return event{ticker: r}
+	case *models.TradesResponse:
+		return event{trades: r}
+	case *models.OrderBookResponse:
+		return event{book: r}
+	case *models.GroupedOrderBookResponse:
+		return event{grouped: r}
+	case *models.OrderResponse:
+		return event{order: r}
+	case *models.FillResponse:
+		return event{fill: r}
+	case *models.MarketsResponse:
+		return event{markets: r}
+	default:
+		return event{custom: response}
+	}
+}
+
+// value returns the response of the event as the public API exposes it.
+func (e event) value() interface{} {
+	switch {
+	case e.ticker != nil:
+		return e.ticker
+	case e.trades != nil:
+		return e.trades
+	case e.book != nil:
+		return e.book
+	case e.grouped != nil:
+		return e.grouped
+	case e.order != nil:
+		return e.order
+	case e.fill != nil:
+		return e.fill
+	case e.markets != nil:
+		return e.markets
+	default:
+		return e.custom
+	}
+}
+
+// frameHeader holds the protocol fields of a websocket frame.
+type frameHeader struct {
+	Channel models.Channel      `json:"channel"`
+	Market  string              `json:"market"`
+	Type    models.ResponseType `json:"type"`
+	Code    int                 `json:"code"`
+	Message string              `json:"msg"`
+}
+
+func (h frameHeader) base() models.BaseResponse {
+	return models.BaseResponse{
+		Type:   h.Type,
+		Kind:   models.EventKindOf(h.Channel, h.Type),
+		Symbol: h.Market,
+	}
+}
+
+// frameDecoder parses a whole frame of a builtin channel in a single pass, decoding data straight
+// into the response instead of copying it to a json.RawMessage first. Prices and sizes are still
+// parsed into decimal.Decimal by encoding/json, so this saves the copy and the interface boxing
+// of the response, not the allocations of the levels.
+type frameDecoder func(frame []byte) (frameHeader, event, error)
+
+var frameDecoders = map[models.Channel]frameDecoder{
+	models.TickerChannel: func(frame []byte) (frameHeader, event, error) {
+		response := &models.TickerResponse{}
+		envelope := struct {
+			frameHeader
+			Data *models.Ticker `json:"data"`
+		}{Data: &response.Ticker}
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{ticker: response}, nil
+	},
+	models.TradesChannel: func(frame []byte) (frameHeader, event, error) {
+		response := &models.TradesResponse{}
+		envelope := struct {
+			frameHeader
+			Data *[]models.Trade `json:"data"`
+		}{Data: &response.Trades}
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{trades: response}, nil
+	},
+	models.OrderBookChannel: func(frame []byte) (frameHeader, event, error) {
+		response := &models.OrderBookResponse{}
+		envelope := struct {
+			frameHeader
+			Data *models.OrderBook `json:"data"`
+		}{Data: &response.OrderBook}
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.IsSnapshot = envelope.Type == models.Partial
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{book: response}, nil
+	},
+	models.GroupedOrderBookChannel: func(frame []byte) (frameHeader, event, error) {
+		type groupedBook struct {
+			*models.OrderBook
+			Grouping decimal.Decimal `json:"grouping"`
+		}
+		response := &models.GroupedOrderBookResponse{}
+		envelope := struct {
+			frameHeader
+			Grouping decimal.Decimal `json:"grouping"`
+			Data     *groupedBook    `json:"data"`
+		}{Data: &groupedBook{OrderBook: &response.OrderBook}}
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.Grouping = envelope.Grouping
+		if response.Grouping.IsZero() {
+			response.Grouping = envelope.Data.Grouping
+		}
+		response.IsSnapshot = envelope.Type == models.Partial
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{grouped: response}, nil
+	},
+	models.OrdersChannel: func(frame []byte) (frameHeader, event, error) {
+		response := &models.OrderResponse{}
+		envelope := struct {
+			frameHeader
+			Data *models.Order `json:"data"`
+		}{Data: &response.Order}
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{order: response}, nil
+	},
+	models.FillsChannel: func(frame []byte) (frameHeader, event, error) {
+		response := &models.FillResponse{}
+		envelope := struct {
+			frameHeader
+			Data *models.Fill `json:"data"`
+		}{Data: &response.Fill}
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{fill: response}, nil
+	},
+	models.MarketsChannel: func(frame []byte) (frameHeader, event, error) {
+		response := &models.MarketsResponse{}
+		envelope := struct {
+			frameHeader
+			Data struct {
+				Data *map[string]*models.Market `json:"data"`
+			} `json:"data"`
+		}{}
+		envelope.Data.Data = &response.Markets
+		if err := json.Unmarshal(frame, &envelope); err != nil {
+			return frameHeader{}, event{}, errors.WithStack(err)
+		}
+		response.BaseResponse = envelope.base()
+		return envelope.frameHeader, event{markets: response}, nil
+	},
+}
+
+// peekChannel finds the channel name of a frame without parsing it. Only keys of the top level
+// object are looked at, so a "channel" key inside data is skipped.
+func peekChannel(frame []byte) (models.Channel, bool) {
+	rest := skipSpace(frame)
+	if len(rest) == 0 || rest[0] != '{' {
+		return "", false
+	}
+	rest = skipSpace(rest[1:])
+	for len(rest) > 0 && rest[0] == '"' {
+		key, tail, ok := scanString(rest)
+		if !ok {
+			return "", false
+		}
+		rest = skipSpace(tail)
+		if len(rest) == 0 || rest[0] != ':' {
+			return "", false
+		}
+		rest = skipSpace(rest[1:])
+		if string(key) == "channel" {
+			value, _, ok := scanString(rest)
+			if !ok {
+				return "", false
+			}
+			return models.Channel(value), true
+		}
+
+		rest, ok = skipValue(rest)
+		if !ok {
+			return "", false
+		}
+		rest = skipSpace(rest)
+		if len(rest) == 0 || rest[0] != ',' {
+			return "", false
+		}
+		rest = skipSpace(rest[1:])
+	}
+	return "", false
+}
+
+func skipSpace(data []byte) []byte {
+	return bytes.TrimLeft(data, " \t\r\n")
+}
+
+// scanString returns the contents of the JSON string at the start of data and the bytes after it.
+// Strings with escapes are reported as not found, channel names and keys never have them.
+func scanString(data []byte) ([]byte, []byte, bool) {
+	if len(data) == 0 || data[0] != '"' {
+		return nil, nil, false
+	}
+	end := bytes.IndexByte(data[1:], '"')
+	if end < 0 || bytes.IndexByte(data[1:end+1], '\\') >= 0 {
+		return nil, nil, false
+	}
+	return data[1 : end+1], data[end+2:], true
+}
+
+// skipValue returns the bytes after the JSON value at the start of data.
+func skipValue(data []byte) ([]byte, bool) {
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			// skip the string, minding escaped quotes
+			i++
+			for i < len(data) && data[i] != '"' {
+				if data[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(data) {
+				return nil, false
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return data[i:], true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return data[i:], true
+			}
+		}
+		if depth == 0 && (data[i] == '"' || data[i] == '}' || data[i] == ']') {
+			return data[i+1:], true
+		}
+	}
+	return nil, false
+}
+
+// decodeFrame decodes a raw frame. Builtin channels take the single pass path,
+// other channels are unmarshalled into models.WsResponse and given to their decoder.
+// Frames of other types than partial and update are returned with an empty event.
+func (r *channelRegistry) decodeFrame(frame []byte) (frameHeader, event, error) {
+	if channel, ok := peekChannel(frame); ok {
+		if decoder, ok := frameDecoders[channel]; ok {
+			return decoder(frame)
+		}
+	}
+
+	message := &models.WsResponse{}
+	err := json.Unmarshal(frame, message)
+	if err != nil {
+		return frameHeader{}, event{}, errors.WithStack(err)
+	}
+
+	header := frameHeader{
+		Channel: message.Channel,
+		Market:  message.Market,
+		Type:    message.Type,
+		Code:    message.Code,
+		Message: message.Message,
+	}
+	if !isDataFrame(header.Type) {
+		return header, event{}, nil
+	}
+
+	response, err := r.decode(message)
+	if err != nil {
+		return header, event{}, err
+	}
+	return header, newEvent(response), nil
+}
+
+func isDataFrame(responseType models.ResponseType) bool {
+	return responseType == models.Partial || responseType == models.Update
+}
+
+// tagSubAccount marks private channel responses with the subaccount of their connection.
+func (e event) tagSubAccount(subAccount string) {
+	switch {
+	case e.fill != nil:
+		e.fill.SubAccount = subAccount
+	case e.order != nil:
+		e.order.SubAccount = subAccount
+	}
+}
+
+// readFrame reads the next message of conn into buf, which is reused between messages.
+// The returned bytes are only valid until the next call.
+func readFrame(conn *websocket.Conn, buf *bytes.Buffer) ([]byte, error) {
+	_, reader, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Reset()
+	_, err = buf.ReadFrom(reader)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// forwardValues adapts events to the interface{} channels of the generic Subscribe methods.
+func forwardValues(ctx context.Context, eventsC chan event) chan interface{} {
+	valuesC := make(chan interface{}, 1)
+	go func() {
+		defer close(valuesC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				select {
+				case valuesC <- ev.value():
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return valuesC
+}
@@ -0,0 +1,137 @@
+package goftx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+var (
+	tickerFrame    = []byte(`{"channel": "ticker", "market": "BTC-PERP", "type": "update", "data": {"bid": 50123.5, "ask": 50124.0, "bidSize": 1.2345, "askSize": 0.5, "last": 50123.5, "time": 1616686458.4370248}}`)
+	tradesFrame    = []byte(`{"channel": "trades", "market": "BTC-PERP", "type": "update", "data": [{"id": 1001, "price": 50123.5, "size": 0.01, "side": "buy", "liquidation": false, "time": "2021-03-25T15:34:18.437024+00:00"}, {"id": 1002, "price": 50124, "size": 0.25, "side": "sell", "liquidation": true, "time": "2021-03-25T15:34:18.537024+00:00"}]}`)
+	orderBookFrame = []byte(`{"channel": "orderbook", "market": "BTC-PERP", "type": "update", "data": {"time": 1616686458.4370248, "checksum": 3115361755, "bids": [[50123.5, 1.2], [50123.0, 0.0], [50122.5, 3.4]], "asks": [[50124.0, 0.5], [50124.5, 2.1]], "action": "update"}}`)
+	ordersFrame    = []byte(`{"channel": "orders", "type": "update", "data": {"id": 24852229, "clientId": null, "market": "XRP-PERP", "type": "limit", "side": "buy", "size": 42353.0, "price": 0.2977, "reduceOnly": false, "ioc": false, "postOnly": false, "status": "closed", "filledSize": 42353.0, "remainingSize": 0.0, "avgFillPrice": 0.2978, "createdAt": "2021-03-25T15:34:18.437024+00:00"}}`)
+	groupedFrame   = []byte(`{"channel": "orderbookGrouped", "market": "BTC-PERP", "type": "partial", "grouping": 500, "data": {"bids": [[50000, 1.2]], "asks": [[50500, 0.5]]}}`)
+	marketsFrame   = []byte(`{"channel": "markets", "type": "partial", "data": {"data": {"BTC-PERP": {"name": "BTC-PERP", "type": "future", "priceIncrement": 0.5, "sizeIncrement": 0.001}}, "action": "partial"}}`)
+	fillsFrame     = []byte(`{"channel": "fills", "type": "update", "data": {"fee": 78.05799225, "feeRate": 0.0014, "future": "BTC-PERP", "id": 7828307, "liquidity": "taker", "market": "BTC-PERP", "orderId": 38065410, "tradeId": 19129310, "price": 3723.75, "side": "buy", "size": 14.973, "time": "2019-05-07T16:40:58.358438+00:00", "type": "order"}}`)
+)
+
+func legacyDecode(frame []byte) (interface{}, error) {
+	message := &models.WsResponse{}
+	err := json.Unmarshal(frame, message)
+	if err != nil {
+		return nil, err
+	}
+
+	switch message.Channel {
+	case models.TickerChannel:
+		return message.MapToTickerResponse()
+	case models.TradesChannel:
+		return message.MapToTradesResponse()
+	case models.OrderBookChannel:
+		return message.MapToOrderBookResponse()
+	case models.OrdersChannel:
+		return message.MapToOrderResponse()
+	case models.FillsChannel:
+		return message.MapToFillResponse()
+	case models.GroupedOrderBookChannel:
+		return message.MapToGroupedOrderBookResponse()
+	case models.MarketsChannel:
+		return message.MapToMarketsResponse()
+	}
+	return nil, errUnknownChannel
+}
+
+func TestChannelRegistry_DecodeFrame(t *testing.T) {
+	registry := newChannelRegistry()
+
+	for _, frame := range [][]byte{tickerFrame, tradesFrame, orderBookFrame, ordersFrame, fillsFrame, groupedFrame, marketsFrame} {
+		expected, err := legacyDecode(frame)
+		require.NoError(t, err)
+
+		header, ev, err := registry.decodeFrame(frame)
+		require.NoError(t, err)
+		require.True(t, isDataFrame(header.Type))
+		require.Equal(t, expected, ev.value())
+	}
+
+	t.Run("control frames", func(t *testing.T) {
+		header, ev, err := registry.decodeFrame([]byte(`{"type": "pong"}`))
+		require.NoError(t, err)
+		require.Equal(t, models.Pong, header.Type)
+		require.Nil(t, ev.value())
+
+		header, ev, err = registry.decodeFrame([]byte(`{"channel": "ticker", "market": "BTC-PERP", "type": "subscribed"}`))
+		require.NoError(t, err)
+		require.Equal(t, models.Subscribed, header.Type)
+		require.Equal(t, "BTC-PERP", header.Market)
+
+		header, _, err = registry.decodeFrame([]byte(`{"type": "error", "code": 400, "msg": "Invalid market"}`))
+		require.NoError(t, err)
+		require.Equal(t, models.Error, header.Type)
+		require.Equal(t, 400, header.Code)
+		require.Equal(t, "Invalid market", header.Message)
+	})
+
+	t.Run("channel of the top level", func(t *testing.T) {
+		for frame, expected := range map[string]models.Channel{
+			`{"type": "update", "data": {"channel": "trades", "x": "}"}, "channel": "ticker"}`: models.TickerChannel,
+			`{"data": [{"channel": "ticker"}], "type": "pong"}`:                                "",
+			`{"market": "a\"b", "channel": "fills"}`:                                           models.FillsChannel,
+		} {
+			channel, _ := peekChannel([]byte(frame))
+			require.Equal(t, expected, channel, frame)
+		}
+	})
+
+	t.Run("frames are not retained", func(t *testing.T) {
+		frame := append([]byte(nil), tickerFrame...)
+		_, ev, err := registry.decodeFrame(frame)
+		require.NoError(t, err)
+
+		for i := range frame {
+			frame[i] = ' '
+		}
+		expected, err := legacyDecode(tickerFrame)
+		require.NoError(t, err)
+		require.Equal(t, expected, ev.value())
+	})
+}
+
+func BenchmarkDecode(b *testing.B) {
+	registry := newChannelRegistry()
+	frames := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "ticker", frame: tickerFrame},
+		{name: "trades", frame: tradesFrame},
+		{name: "orderbook", frame: orderBookFrame},
+	}
+
+	for _, f := range frames {
+		b.Run(f.name+"/legacy", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(f.frame)))
+			for i := 0; i < b.N; i++ {
+				response, err := legacyDecode(f.frame)
+				if err != nil || response == nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(f.name+"/frame", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(f.frame)))
+			for i := 0; i < b.N; i++ {
+				_, ev, err := registry.decodeFrame(f.frame)
+				if err != nil || ev.value() == nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package goftx
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// SubscribeToFixedTickers subscribes to tickers of the markets of scales and converts them
+// into the fixed-point representation. Tickers which do not fit their scale are dropped.
+//
+// Frames are decoded into decimals first and converted after, so the fixed channels cost more
+// to decode than their decimal counterparts; they pay off where the values are compared or merged.
+func (s *Stream) SubscribeToFixedTickers(ctx context.Context, scales map[string]models.FixedScale) (chan *models.FixedTickerResponse, error) {
+	ctx, _, eventsC, err := s.serveFixed(ctx, models.TickerChannel, scales)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tickersC := make(chan *models.FixedTickerResponse, 1)
+	go func() {
+		defer close(tickersC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				ticker := ev.ticker
+				if ticker == nil {
+					continue
+				}
+				fixed, err := scales[ticker.Symbol].Ticker(ticker.Ticker)
+				if err != nil {
+					s.printf("channel %v market %v: %v", models.TickerChannel, ticker.Symbol, err)
+					continue
+				}
+				select {
+				case tickersC <- &models.FixedTickerResponse{
+					FixedTicker:  fixed,
+					BaseResponse: ticker.BaseResponse,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return tickersC, nil
+}
+
+// SubscribeToFixedTrades subscribes to trades of the markets of scales and converts them
+// into the fixed-point representation. Trades which do not fit their scale are dropped.
+func (s *Stream) SubscribeToFixedTrades(ctx context.Context, scales map[string]models.FixedScale) (chan *models.FixedTradeResponse, error) {
+	ctx, _, eventsC, err := s.serveFixed(ctx, models.TradesChannel, scales)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tradesC := make(chan *models.FixedTradeResponse, 1)
+	go func() {
+		defer close(tradesC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				trades := ev.trades
+				if trades == nil {
+					continue
+				}
+				for _, trade := range trades.Trades {
+					fixed, err := scales[trades.Symbol].Trade(trade)
+					if err != nil {
+						s.printf("channel %v market %v: %v", models.TradesChannel, trades.Symbol, err)
+						continue
+					}
+					select {
+					case tradesC <- &models.FixedTradeResponse{
+						FixedTrade:   fixed,
+						BaseResponse: trades.BaseResponse,
+					}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return tradesC, nil
+}
+
+// SubscribeToFixedOrderBooks subscribes to orderbooks of the markets of scales and converts them
+// into the fixed-point representation, which could be merged with FixedOrderBook.Apply.
+// A book which does not fit its scale is dropped and its market is resubscribed once, updates are
+// skipped until the new partial, so a consumer never applies an update on top of a gap.
+func (s *Stream) SubscribeToFixedOrderBooks(ctx context.Context, scales map[string]models.FixedScale) (chan *models.FixedOrderBookResponse, error) {
+	ctx, conn, eventsC, err := s.serveFixed(ctx, models.OrderBookChannel, scales)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	booksC := make(chan *models.FixedOrderBookResponse, 1)
+	go func() {
+		defer close(booksC)
+		// resyncing has the markets waiting for a new partial
+		resyncing := make(map[string]bool)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				book := ev.book
+				if book == nil {
+					continue
+				}
+				if resyncing[book.Symbol] && !book.IsSnapshot {
+					continue
+				}
+				fixed, err := scales[book.Symbol].OrderBook(book.OrderBook)
+				if err != nil {
+					s.printf("channel %v market %v: %v", models.OrderBookChannel, book.Symbol, err)
+					if !resyncing[book.Symbol] {
+						resyncing[book.Symbol] = true
+						err = conn.resubscribe(models.WSRequest{Channel: models.OrderBookChannel, Market: book.Symbol, Op: models.Subscribe})
+						if err != nil {
+							s.printf("channel %v market %v resubscribe: %v", models.OrderBookChannel, book.Symbol, err)
+						}
+					}
+					continue
+				}
+				delete(resyncing, book.Symbol)
+				select {
+				case booksC <- &models.FixedOrderBookResponse{
+					FixedOrderBook: fixed,
+					IsSnapshot:     book.IsSnapshot,
+					BaseResponse:   book.BaseResponse,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return booksC, nil
+}
+
+func (s *Stream) serveFixed(ctx context.Context, channel models.Channel, scales map[string]models.FixedScale) (context.Context, *connection, chan event, error) {
+	if len(scales) == 0 {
+		return nil, nil, nil, errors.New("scales is missing")
+	}
+
+	requests := make([]models.WSRequest, 0, len(scales))
+	for symbol := range scales {
+		requests = append(requests, models.WSRequest{
+			Channel: channel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+		})
+	}
+
+	return s.serveConn(ctx, requests)
+}
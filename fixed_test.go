@@ -0,0 +1,218 @@
+package goftx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestFixedScale(t *testing.T) {
+	scale := models.Market{
+		PriceIncrement: decimal.RequireFromString("0.50"),
+		SizeIncrement:  decimal.RequireFromString("0.0001"),
+	}.FixedScale()
+	require.Equal(t, models.FixedScale{Price: 1, Size: 4}, scale)
+	require.Equal(t, models.FixedScale{Price: 0, Size: 0}, models.NewFixedScale(decimal.NewFromInt(25), decimal.NewFromInt(1)))
+
+	price, err := scale.ToPrice(decimal.RequireFromString("50123.5"))
+	require.NoError(t, err)
+	require.EqualValues(t, 501235, price)
+	require.True(t, scale.FromPrice(price).Equal(decimal.RequireFromString("50123.5")))
+
+	size, err := scale.ToSize(decimal.RequireFromString("12e-4"))
+	require.NoError(t, err)
+	require.EqualValues(t, 12, size)
+
+	_, err = scale.ToPrice(decimal.RequireFromString("50123.55"))
+	require.Error(t, err)
+	_, err = models.ToFixed(decimal.RequireFromString("1e30"), 0)
+	require.Error(t, err)
+
+	book := models.OrderBook{
+		Bids:     [][]decimal.Decimal{{decimal.RequireFromString("100.5"), decimal.RequireFromString("1.25")}},
+		Asks:     [][]decimal.Decimal{{decimal.RequireFromString("101"), decimal.RequireFromString("0.0001")}},
+		Checksum: 42,
+	}
+	fixed, err := scale.OrderBook(book)
+	require.NoError(t, err)
+	require.Equal(t, []models.FixedLevel{{Price: 1005, Size: 12500}}, fixed.Bids)
+	roundTrip := fixed.Decimal()
+	require.True(t, roundTrip.Bids[0][0].Equal(book.Bids[0][0]))
+	require.True(t, roundTrip.Asks[0][1].Equal(book.Asks[0][1]))
+	require.Equal(t, book.Checksum, roundTrip.Checksum)
+
+	ticker := models.Ticker{
+		Bid:     decimal.RequireFromString("100.5"),
+		Ask:     decimal.RequireFromString("101"),
+		BidSize: decimal.RequireFromString("3"),
+		AskSize: decimal.RequireFromString("0.25"),
+		Last:    decimal.RequireFromString("100.5"),
+	}
+	fixedTicker, err := scale.Ticker(ticker)
+	require.NoError(t, err)
+	require.EqualValues(t, 2500, fixedTicker.AskSize)
+	require.True(t, fixedTicker.Decimal().Ask.Equal(ticker.Ask))
+}
+
+func TestStream_SubscribeToFixedOrderBooks(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Partial,
+			"data": map[string]interface{}{
+				"bids": [][]float64{{9000, 1}, {8500, 2}},
+				"asks": [][]float64{{9500, 3}, {10000, 4}},
+			},
+		})
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data": map[string]interface{}{
+				"bids": [][]float64{{9000, 0}, {8000, 5}},
+				"asks": [][]float64{{9500, 1.5}, {9000.5, 2}},
+			},
+		})
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data, err := ftx.Stream.SubscribeToFixedOrderBooks(ctx, map[string]models.FixedScale{
+		"BTC-PERP": {Price: 1, Size: 1},
+	})
+	require.NoError(t, err)
+
+	book := models.FixedOrderBook{}
+	for _, expected := range []models.ResponseType{models.Partial, models.Update} {
+		select {
+		case msg := <-data:
+			require.Equal(t, expected, msg.Type)
+			require.Equal(t, "BTC-PERP", msg.Symbol)
+			book.Apply(msg.Type, msg.FixedOrderBook)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no orderbook received")
+		}
+	}
+
+	require.Equal(t, []models.FixedLevel{{Price: 85000, Size: 20}, {Price: 80000, Size: 50}}, book.Bids)
+	require.Equal(t, []models.FixedLevel{{Price: 90005, Size: 20}, {Price: 95000, Size: 15}, {Price: 100000, Size: 40}}, book.Asks)
+	bid, ok := book.BestBid()
+	require.True(t, ok)
+	require.True(t, book.Scale.FromPrice(bid.Price).Equal(decimal.NewFromInt(8500)))
+}
+
+func TestStream_SubscribeToFixedOrderBooks_Resync(t *testing.T) {
+	subscriptions := 0
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		subscriptions++
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Partial,
+			"data":    map[string]interface{}{"bids": [][]float64{{9000, 1}}, "asks": [][]float64{{9500, 3}}},
+		})
+		price := 8000.5
+		if subscriptions == 1 {
+			// does not fit the scale, so the rest of the book is out of sync
+			price = 8000.25
+		}
+		for _, bid := range []float64{price, 7000} {
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": req.Channel,
+				"market":  req.Market,
+				"type":    models.Update,
+				"data":    map[string]interface{}{"bids": [][]float64{{bid, 1}}, "asks": [][]float64{}},
+			})
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data, err := ftx.Stream.SubscribeToFixedOrderBooks(ctx, map[string]models.FixedScale{
+		"BTC-PERP": {Price: 1, Size: 1},
+	})
+	require.NoError(t, err)
+
+	var types []models.ResponseType
+	var bids []int64
+	for len(types) < 4 {
+		select {
+		case msg := <-data:
+			types = append(types, msg.Type)
+			if msg.Type == models.Update {
+				bids = append(bids, msg.Bids[0].Price)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no orderbook received, got %v", types)
+		}
+	}
+	require.Equal(t, []models.ResponseType{models.Partial, models.Partial, models.Update, models.Update}, types)
+	require.Equal(t, []int64{80005, 70000}, bids)
+
+	var ops []models.Operation
+	for len(ops) < 3 {
+		select {
+		case req := <-ts.requestsC:
+			ops = append(ops, req.Op)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no resubscribe, got %v", ops)
+		}
+	}
+	require.Equal(t, []models.Operation{models.Subscribe, models.UnSubscribe, models.Subscribe}, ops)
+}
+
+func BenchmarkOrderBookApply(b *testing.B) {
+	scale := models.FixedScale{Price: 1, Size: 3}
+	partial := models.OrderBook{}
+	update := models.OrderBook{}
+	for i := 0; i < 100; i++ {
+		price := decimal.New(int64(50000*10+i*5), -1)
+		level := []decimal.Decimal{price, decimal.New(int64(i+1), -3)}
+		partial.Bids = append([][]decimal.Decimal{level}, partial.Bids...)
+		if i%10 == 0 {
+			update.Bids = append(update.Bids, []decimal.Decimal{price, decimal.New(int64(i+2), -3)})
+		}
+	}
+	fixedPartial, err := scale.OrderBook(partial)
+	require.NoError(b, err)
+	fixedUpdate, err := scale.OrderBook(update)
+	require.NoError(b, err)
+
+	b.Run("decimal", func(b *testing.B) {
+		b.ReportAllocs()
+		book := models.OrderBook{}
+		book.Apply(models.Partial, partial)
+		for i := 0; i < b.N; i++ {
+			book.Apply(models.Update, update)
+		}
+	})
+	b.Run("fixed", func(b *testing.B) {
+		b.ReportAllocs()
+		book := models.FixedOrderBook{}
+		book.Apply(models.Partial, fixedPartial)
+		for i := 0; i < b.N; i++ {
+			book.Apply(models.Update, fixedUpdate)
+		}
+	})
+}
@@ -0,0 +1,428 @@
+package goftx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const hubBufferSize = 64
+
+type HubOption func(h *Hub)
+
+// WithHubBufferSize sets how many events every consumer could fall behind before its events are dropped.
+func WithHubBufferSize(size int) HubOption {
+	return func(h *Hub) {
+		h.bufferSize = size
+	}
+}
+
+// Hub shares subscriptions of a Stream between consumers. Every (channel, market) is subscribed once
+// on a single connection of the hub and its events are fanned out to all consumers of it,
+// each consumer has its own buffer, so a slow consumer loses its own events instead of blocking the others.
+// A (channel, market) is unsubscribed when its last consumer leaves, the connection is closed
+// once no subscriptions are left.
+// Responses are shared between consumers and must not be modified, a consumer that lost
+// orderbook updates to a full buffer should resubscribe to get a consistent book.
+type Hub struct {
+	stream     *Stream
+	bufferSize int
+
+	// dialMu makes concurrent feeds wait for one connection instead of opening their own.
+	dialMu sync.Mutex
+	// subMu orders subscribe and unsubscribe writes, which are done outside of mu.
+	subMu    sync.Mutex
+	mu       sync.Mutex
+	feeds    map[string]*hubFeed
+	upstream *hubUpstream
+}
+
+// hubUpstream is the connection shared by the feeds of a hub.
+type hubUpstream struct {
+	conn   *connection
+	cancel context.CancelFunc
+	feeds  int
+}
+
+type hubFeed struct {
+	channel  models.Channel
+	market   string
+	readyC   chan struct{}
+	err      error
+	upstream *hubUpstream
+	done     bool
+
+	consumers map[*hubConsumer]bool
+	// book and ticker keep the state that is replayed to late consumers.
+	book   *models.OrderBook
+	ticker *models.TickerResponse
+}
+
+type hubConsumer struct {
+	eventsC chan event
+	doneC   chan struct{}
+	feeds   int
+	closed  bool
+}
+
+func (c *hubConsumer) close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.eventsC)
+	close(c.doneC)
+}
+
+func NewHub(stream *Stream, opts ...HubOption) *Hub {
+	h := &Hub{
+		stream:     stream,
+		bufferSize: hubBufferSize,
+		feeds:      make(map[string]*hubFeed),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// SubscribeToTickers shares ticker subscriptions, a consumer joining a running subscription gets the last ticker first.
+func (h *Hub) SubscribeToTickers(ctx context.Context, symbols ...string) (chan *models.TickerResponse, error) {
+	ctx, eventsC, err := h.subscribeToMarkets(ctx, models.TickerChannel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTickers(ctx, eventsC), nil
+}
+
+func (h *Hub) SubscribeToTrades(ctx context.Context, symbols ...string) (chan *models.TradeResponse, error) {
+	ctx, eventsC, err := h.subscribeToMarkets(ctx, models.TradesChannel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTrades(ctx, eventsC), nil
+}
+
+// SubscribeToOrderBooks shares orderbook subscriptions. The hub keeps every book up to date,
+// so a consumer joining a running subscription gets the current book as a partial first.
+func (h *Hub) SubscribeToOrderBooks(ctx context.Context, symbols ...string) (chan *models.OrderBookResponse, error) {
+	ctx, eventsC, err := h.subscribeToMarkets(ctx, models.OrderBookChannel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardOrderBooks(ctx, eventsC), nil
+}
+
+// Subscribe shares subscriptions of any registered channel, a channel without markets is subscribed once.
+func (h *Hub) Subscribe(ctx context.Context, channel models.Channel, symbols ...string) (chan interface{}, error) {
+	if _, ok := h.stream.channels.get(channel); !ok {
+		return nil, errors.Errorf("channel %v is not registered", channel)
+	}
+	if len(symbols) == 0 {
+		symbols = []string{""}
+	}
+
+	ctx, eventsC, err := h.subscribe(ctx, channel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardValues(ctx, eventsC), nil
+}
+
+func (h *Hub) subscribeToMarkets(ctx context.Context, channel models.Channel, symbols []string) (context.Context, chan event, error) {
+	if len(symbols) == 0 {
+		return nil, nil, errors.New("symbols is missing")
+	}
+
+	return h.subscribe(ctx, channel, symbols)
+}
+
+func (h *Hub) subscribe(ctx context.Context, channel models.Channel, symbols []string) (context.Context, chan event, error) {
+	consumer := &hubConsumer{
+		eventsC: make(chan event, h.bufferSize+len(symbols)),
+		doneC:   make(chan struct{}),
+	}
+
+	var joined []*hubFeed
+	for _, symbol := range symbols {
+		feed, err := h.join(channel, symbol, consumer)
+		if err != nil {
+			h.leave(consumer, joined)
+			return nil, nil, errors.WithStack(err)
+		}
+		joined = append(joined, feed)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.leave(consumer, joined)
+		case <-consumer.doneC:
+		}
+	}()
+
+	return ctx, consumer.eventsC, nil
+}
+
+// join adds consumer to the feed of channel and market, starting the feed if it is not running.
+func (h *Hub) join(channel models.Channel, market string, consumer *hubConsumer) (*hubFeed, error) {
+	key := feedKey(channel, market)
+	for {
+		h.mu.Lock()
+		feed, ok := h.feeds[key]
+		if !ok {
+			feed = &hubFeed{
+				channel:   channel,
+				market:    market,
+				readyC:    make(chan struct{}),
+				consumers: make(map[*hubConsumer]bool),
+			}
+			h.feeds[key] = feed
+			h.mu.Unlock()
+
+			h.start(feed)
+		} else {
+			h.mu.Unlock()
+			<-feed.readyC
+		}
+		if feed.err != nil {
+			return nil, feed.err
+		}
+
+		h.mu.Lock()
+		if feed.done {
+			// the feed ended before the consumer joined, start a new one
+			h.mu.Unlock()
+			continue
+		}
+		feed.consumers[consumer] = true
+		consumer.feeds++
+		h.replay(feed, consumer)
+		h.mu.Unlock()
+
+		return feed, nil
+	}
+}
+
+func (h *Hub) start(feed *hubFeed) {
+	defer close(feed.readyC)
+
+	req := models.WSRequest{
+		Channel: feed.channel,
+		Market:  feed.market,
+		Op:      models.Subscribe,
+	}
+	if definition, ok := h.stream.channels.get(feed.channel); ok {
+		req.Args = definition.Args
+	}
+
+	upstream, err := h.acquire(feed)
+	if err != nil {
+		feed.err = err
+
+		h.mu.Lock()
+		feed.done = true
+		if h.feeds[feedKey(feed.channel, feed.market)] == feed {
+			delete(h.feeds, feedKey(feed.channel, feed.market))
+		}
+		h.mu.Unlock()
+		return
+	}
+
+	// a failed write breaks the connection, the request is sent again on reconnect
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	if err := upstream.conn.add(req, h.stream.subscribe); err != nil {
+		h.stream.printf("hub subscribe %v %v: %v", feed.channel, feed.market, err)
+	}
+}
+
+// acquire attaches feed to the connection of the hub, opening the connection for the first feed.
+func (h *Hub) acquire(feed *hubFeed) (*hubUpstream, error) {
+	h.dialMu.Lock()
+	defer h.dialMu.Unlock()
+
+	h.mu.Lock()
+	if upstream := h.upstream; upstream != nil {
+		upstream.feeds++
+		feed.upstream = upstream
+		h.mu.Unlock()
+		return upstream, nil
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, conn, eventsC, err := h.stream.serveConn(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, errors.WithStack(err)
+	}
+	upstream := &hubUpstream{
+		conn:   conn,
+		cancel: cancel,
+		feeds:  1,
+	}
+
+	h.mu.Lock()
+	h.upstream = upstream
+	feed.upstream = upstream
+	h.mu.Unlock()
+
+	go func() {
+		for ev := range eventsC {
+			h.dispatch(upstream, ev)
+		}
+		h.end(upstream)
+	}()
+
+	return upstream, nil
+}
+
+// release detaches a feed from upstream and closes the connection after its last feed,
+// it reports whether the connection was closed.
+func (h *Hub) release(upstream *hubUpstream) bool {
+	upstream.feeds--
+	if upstream.feeds > 0 {
+		return false
+	}
+	upstream.cancel()
+	if h.upstream == upstream {
+		h.upstream = nil
+	}
+	return true
+}
+
+// replay sends the state of a running feed to a consumer that joins it.
+func (h *Hub) replay(feed *hubFeed, consumer *hubConsumer) {
+	switch {
+	case feed.book != nil:
+		h.send(feed, consumer, event{book: &models.OrderBookResponse{
+			OrderBook: models.OrderBook{
+				Bids:     append([][]decimal.Decimal(nil), feed.book.Bids...),
+				Asks:     append([][]decimal.Decimal(nil), feed.book.Asks...),
+				Checksum: feed.book.Checksum,
+				Time:     feed.book.Time,
+			},
+			IsSnapshot: true,
+			BaseResponse: models.BaseResponse{
+				Type:   models.Partial,
+				Kind:   models.SnapshotEvent,
+				Symbol: feed.market,
+			},
+		}})
+	case feed.ticker != nil:
+		h.send(feed, consumer, event{ticker: feed.ticker})
+	}
+}
+
+// send delivers ev to consumer without blocking, an event that does not fit the buffer is dropped.
+func (h *Hub) send(feed *hubFeed, consumer *hubConsumer, ev event) {
+	select {
+	case consumer.eventsC <- ev:
+	default:
+		h.stream.streamMetrics().MessageDropped(feed.channel, feed.market)
+	}
+}
+
+func (h *Hub) dispatch(upstream *hubUpstream, ev event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	feed, ok := h.feeds[feedKey(ev.channel, ev.market)]
+	if !ok || feed.upstream != upstream {
+		return
+	}
+
+	switch {
+	case ev.book != nil:
+		if feed.book == nil {
+			if ev.book.Type != models.Partial {
+				break
+			}
+			feed.book = &models.OrderBook{}
+		}
+		feed.book.Apply(ev.book.Type, ev.book.OrderBook)
+	case ev.ticker != nil:
+		feed.ticker = ev.ticker
+	}
+
+	for consumer := range feed.consumers {
+		h.send(feed, consumer, ev)
+	}
+}
+
+// end removes the feeds of a connection that stopped and closes consumers left without feeds.
+func (h *Hub) end(upstream *hubUpstream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	upstream.cancel()
+	if h.upstream == upstream {
+		h.upstream = nil
+	}
+	for key, feed := range h.feeds {
+		if feed.upstream != upstream {
+			continue
+		}
+		feed.done = true
+		delete(h.feeds, key)
+		for consumer := range feed.consumers {
+			consumer.feeds--
+			if consumer.feeds == 0 {
+				consumer.close()
+			}
+		}
+		feed.consumers = nil
+	}
+}
+
+// leave removes consumer from feeds and unsubscribes the feeds it was the last consumer of.
+// Unsubscribe writes are done after unlocking, so a slow write does not stall dispatch;
+// subMu is taken before unlocking to keep them ahead of a new subscription of the same feed.
+func (h *Hub) leave(consumer *hubConsumer, feeds []*hubFeed) {
+	h.mu.Lock()
+
+	var removed []*hubFeed
+	for _, feed := range feeds {
+		if feed.done || !feed.consumers[consumer] {
+			continue
+		}
+		delete(feed.consumers, consumer)
+		consumer.feeds--
+		if len(feed.consumers) > 0 {
+			continue
+		}
+
+		feed.done = true
+		if h.feeds[feedKey(feed.channel, feed.market)] == feed {
+			delete(h.feeds, feedKey(feed.channel, feed.market))
+		}
+		// a closed connection unsubscribes its requests itself on shutdown
+		if !h.release(feed.upstream) {
+			removed = append(removed, feed)
+		}
+	}
+	consumer.close()
+
+	if len(removed) == 0 {
+		h.mu.Unlock()
+		return
+	}
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	h.mu.Unlock()
+
+	for _, feed := range removed {
+		if err := feed.upstream.conn.remove(feed.channel, feed.market); err != nil {
+			h.stream.printf("hub unsubscribe %v %v: %v", feed.channel, feed.market, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package goftx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestHub_SubscribeToTrades(t *testing.T) {
+	connC := make(chan *websocket.Conn, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe {
+			connC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	hub := NewHub(&ftx.Stream)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	trades1, err := hub.SubscribeToTrades(ctx1, "BTC-PERP")
+	require.NoError(t, err)
+	trades2, err := hub.SubscribeToTrades(ctx2, "BTC-PERP")
+	require.NoError(t, err)
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-connC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no subscription")
+	}
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"channel": models.TradesChannel,
+		"market":  "BTC-PERP",
+		"type":    models.Update,
+		"data":    []map[string]interface{}{{"id": 1, "price": 9000, "size": 1, "side": "buy"}},
+	}))
+
+	for _, tradesC := range []chan *models.TradeResponse{trades1, trades2} {
+		select {
+		case trade := <-tradesC:
+			require.EqualValues(t, 1, trade.ID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no trade received")
+		}
+	}
+
+	var subscribes int
+	for len(ts.requestsC) > 0 {
+		if req := <-ts.requestsC; req.Op == models.Subscribe {
+			subscribes++
+		}
+	}
+	require.Equal(t, 1, subscribes)
+
+	cancel1()
+	select {
+	case req := <-ts.requestsC:
+		t.Fatalf("unexpected request %+v", req)
+	case <-time.After(200 * time.Millisecond):
+	}
+	_, ok := <-trades1
+	require.False(t, ok)
+
+	cancel2()
+	select {
+	case req := <-ts.requestsC:
+		require.Equal(t, models.UnSubscribe, req.Op)
+		require.Equal(t, models.TradesChannel, req.Channel)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no unsubscribe after the last consumer left")
+	}
+}
+
+func TestHub_SubscribeToOrderBooks(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Partial,
+			"data": map[string]interface{}{
+				"bids": [][]float64{{9000, 1}, {8500, 2}},
+				"asks": [][]float64{{9500, 3}},
+			},
+		})
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data": map[string]interface{}{
+				"bids": [][]float64{{9000, 0}},
+				"asks": [][]float64{{9400, 1}},
+			},
+		})
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	hub := NewHub(&ftx.Stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, err := hub.SubscribeToOrderBooks(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	book := models.OrderBook{}
+	for _, expected := range []models.ResponseType{models.Partial, models.Update} {
+		select {
+		case msg := <-first:
+			require.Equal(t, expected, msg.Type)
+			book.Apply(msg.Type, msg.OrderBook)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no orderbook received")
+		}
+	}
+
+	late, err := hub.SubscribeToOrderBooks(ctx, "BTC-PERP")
+	require.NoError(t, err)
+	select {
+	case msg := <-late:
+		require.Equal(t, models.Partial, msg.Type)
+		require.Equal(t, "BTC-PERP", msg.Symbol)
+		require.Equal(t, book.Bids, msg.Bids)
+		require.Equal(t, book.Asks, msg.Asks)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no snapshot for the late consumer")
+	}
+}
+
+func TestHub_SharedConnection(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data":    map[string]interface{}{"bid": 9000, "ask": 9001, "last": 9000},
+		})
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	hub := NewHub(&ftx.Stream)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	btc, err := hub.SubscribeToTickers(ctx1, "BTC-PERP")
+	require.NoError(t, err)
+	eth, err := hub.SubscribeToTickers(ctx2, "ETH-PERP")
+	require.NoError(t, err)
+
+	for symbol, tickersC := range map[string]chan *models.TickerResponse{"BTC-PERP": btc, "ETH-PERP": eth} {
+		select {
+		case ticker := <-tickersC:
+			require.Equal(t, symbol, ticker.Symbol)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no ticker received for %v", symbol)
+		}
+	}
+	require.Len(t, ts.handshakeHeaders(), 1)
+
+	for len(ts.requestsC) > 0 {
+		<-ts.requestsC
+	}
+	cancel1()
+	select {
+	case req := <-ts.requestsC:
+		require.Equal(t, models.UnSubscribe, req.Op)
+		require.Equal(t, "BTC-PERP", req.Market)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no unsubscribe after the consumer left")
+	}
+	_, ok := <-btc
+	require.False(t, ok)
+
+	select {
+	case _, ok := <-eth:
+		require.True(t, ok)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestHub_ReplayFullBuffer(t *testing.T) {
+	ftx := New()
+	hub := NewHub(&ftx.Stream)
+
+	feed := &hubFeed{
+		channel: models.TickerChannel,
+		market:  "BTC-PERP",
+		ticker:  &models.TickerResponse{},
+	}
+	consumer := &hubConsumer{eventsC: make(chan event)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		hub.replay(feed, consumer)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("replay blocked on a full buffer")
+	}
+
+	metrics, ok := ftx.Stream.Metrics().(*MemoryMetrics)
+	require.True(t, ok)
+	dropped, ok := metrics.Snapshot().Feed(models.TickerChannel, "BTC-PERP")
+	require.True(t, ok)
+	require.EqualValues(t, 1, dropped.Dropped)
+}
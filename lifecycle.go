@@ -0,0 +1,83 @@
+package goftx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var errStreamClosed = errors.New("stream is closed")
+
+// lifecycle accounts serve loops of a Stream, so Close could stop all of them
+// and Wait could block until their goroutines and sockets are released.
+type lifecycle struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	nextID  int
+	cancels map[int]context.CancelFunc
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		cancels: make(map[int]context.CancelFunc),
+	}
+}
+
+// start registers a serve loop. The returned context is cancelled by close,
+// release must be called once the loop and all of its goroutines have exited.
+func (l *lifecycle) start(ctx context.Context) (context.Context, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, nil, errStreamClosed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	id := l.nextID
+	l.nextID++
+	l.cancels[id] = cancel
+	l.wg.Add(1)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.cancels, id)
+			l.mu.Unlock()
+
+			cancel()
+			l.wg.Done()
+		})
+	}
+
+	return ctx, release, nil
+}
+
+func (l *lifecycle) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	for _, cancel := range l.cancels {
+		cancel()
+	}
+}
+
+func (l *lifecycle) wait() {
+	l.wg.Wait()
+}
+
+// Close stops every subscription of the stream: each connection unsubscribes,
+// sends a close frame, drains pending messages and closes its socket.
+// Subscriptions are refused after Close.
+func (s *Stream) Close() {
+	s.lifecycle.close()
+}
+
+// Wait blocks until every subscription of the stream has released its goroutines and socket.
+func (s *Stream) Wait() {
+	s.lifecycle.wait()
+}
@@ -0,0 +1,104 @@
+package goftx
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestStream_CloseWait(t *testing.T) {
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+
+	ctx := context.Background()
+	_, err := ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+	_, err = ftx.Stream.SubscribeToTrades(ctx, "BTC-PERP", "ETH-PERP")
+	require.NoError(t, err)
+
+	ftx.Stream.Close()
+
+	waitC := make(chan struct{})
+	go func() {
+		ftx.Stream.Wait()
+		close(waitC)
+	}()
+	select {
+	case <-waitC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream is not released")
+	}
+
+	_, err = ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.Error(t, err)
+
+	var ops []models.Operation
+	for len(ts.requestsC) > 0 {
+		req := <-ts.requestsC
+		if req.Channel == models.TradesChannel {
+			ops = append(ops, req.Op)
+		}
+	}
+	require.Equal(t, []models.Operation{models.Subscribe, models.Subscribe, models.UnSubscribe, models.UnSubscribe}, ops)
+}
+
+func TestStream_NoGoroutineLeaks(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		for i := 0; i < 5; i++ {
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": req.Channel,
+				"market":  req.Market,
+				"type":    models.Update,
+				"data":    []map[string]interface{}{{"id": i, "price": 1, "size": 1, "side": "buy"}},
+			})
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.Stream.SetReconnectionInterval(10 * time.Millisecond)
+
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		data, err := ftx.Stream.SubscribeToTrades(ctx, "BTC-PERP")
+		require.NoError(t, err)
+
+		// read some messages, abandon the rest
+		<-data
+		if i%10 == 0 {
+			ts.dropConnections()
+		}
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := ftx.Stream.SubscribeToTrades(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	ftx.Stream.Close()
+	ftx.Stream.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := runtime.NumGoroutine(); n > baseline {
+		buf := make([]byte, 1<<20)
+		t.Fatalf("%d goroutines leaked:\n%s", n-baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
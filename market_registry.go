@@ -0,0 +1,173 @@
+package goftx
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// MarketRegistry maintains the state of all markets from the markets channel
+// and turns every partial and update into typed change events.
+type MarketRegistry struct {
+	mu      sync.RWMutex
+	markets map[string]*models.Market
+}
+
+func NewMarketRegistry() *MarketRegistry {
+	return &MarketRegistry{
+		markets: make(map[string]*models.Market),
+	}
+}
+
+// Apply merges a markets channel message into the registry and returns the resulting events.
+// A partial replaces the whole registry, markets missing from it are reported as delisted.
+// The very first partial only seeds the registry and does not report every market as listed.
+func (r *MarketRegistry) Apply(response *models.MarketsResponse) []*models.MarketEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seeded := len(r.markets) > 0
+
+	names := make([]string, 0, len(response.Markets))
+	for name := range response.Markets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var events []*models.MarketEvent
+	for _, name := range names {
+		market := response.Markets[name]
+		if market == nil {
+			continue
+		}
+		if market.Name == "" {
+			market.Name = name
+		}
+
+		previous, ok := r.markets[name]
+		r.markets[name] = market
+		if !ok {
+			if seeded || response.Type == models.Update {
+				events = append(events, &models.MarketEvent{Type: models.MarketListed, Market: market})
+			}
+			continue
+		}
+		events = append(events, diffMarkets(previous, market)...)
+	}
+
+	if response.Type == models.Partial {
+		var delisted []string
+		for name := range r.markets {
+			if _, ok := response.Markets[name]; !ok {
+				delisted = append(delisted, name)
+			}
+		}
+		sort.Strings(delisted)
+		for _, name := range delisted {
+			events = append(events, &models.MarketEvent{Type: models.MarketDelisted, Market: r.markets[name]})
+			delete(r.markets, name)
+		}
+	}
+
+	return events
+}
+
+func (r *MarketRegistry) Get(name string) (*models.Market, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	market, ok := r.markets[name]
+	return market, ok
+}
+
+// Snapshot returns a copy of the registry keyed by market name.
+func (r *MarketRegistry) Snapshot() map[string]*models.Market {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string]*models.Market, len(r.markets))
+	for name, market := range r.markets {
+		result[name] = market
+	}
+	return result
+}
+
+func diffMarkets(previous, market *models.Market) []*models.MarketEvent {
+	var events []*models.MarketEvent
+	add := func(eventType models.MarketEventType) {
+		events = append(events, &models.MarketEvent{
+			Type:     eventType,
+			Market:   market,
+			Previous: previous,
+		})
+	}
+
+	if previous.Enabled != market.Enabled {
+		if market.Enabled {
+			add(models.MarketEnabled)
+		} else {
+			add(models.MarketDisabled)
+		}
+	}
+	if previous.PostOnly != market.PostOnly {
+		if market.PostOnly {
+			add(models.MarketPostOnlyEnabled)
+		} else {
+			add(models.MarketPostOnlyDisabled)
+		}
+	}
+	if !previous.PriceIncrement.Equal(market.PriceIncrement) {
+		add(models.MarketPriceIncrementChanged)
+	}
+	if !previous.SizeIncrement.Equal(market.SizeIncrement) {
+		add(models.MarketSizeIncrementChanged)
+	}
+	if !previous.MinProvideSize.Equal(market.MinProvideSize) {
+		add(models.MarketMinProvideSizeChanged)
+	}
+
+	return events
+}
+
+// SubscribeToMarketEvents feeds registry from the markets channel and returns its change events.
+func (s *Stream) SubscribeToMarketEvents(ctx context.Context, registry *MarketRegistry) (chan *models.MarketEvent, error) {
+	ctx, eventsC, err := s.serve(ctx, models.WSRequest{
+		Channel: models.MarketsChannel,
+		Op:      models.Subscribe,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	marketEventsC := make(chan *models.MarketEvent, 1)
+	go func() {
+		defer close(marketEventsC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				markets := ev.markets
+				if markets == nil {
+					continue
+				}
+				for _, marketEvent := range registry.Apply(markets) {
+					select {
+					case marketEventsC <- marketEvent:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return marketEventsC, nil
+}
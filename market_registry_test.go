@@ -0,0 +1,74 @@
+package goftx
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestMarketRegistry_Apply(t *testing.T) {
+	market := func(name string, enabled, postOnly bool, tick string) *models.Market {
+		return &models.Market{
+			Name:           name,
+			Enabled:        enabled,
+			PostOnly:       postOnly,
+			PriceIncrement: decimal.RequireFromString(tick),
+			SizeIncrement:  decimal.RequireFromString("0.001"),
+			MinProvideSize: decimal.RequireFromString("0.001"),
+		}
+	}
+	eventTypes := func(events []*models.MarketEvent) []models.MarketEventType {
+		result := make([]models.MarketEventType, 0, len(events))
+		for _, event := range events {
+			result = append(result, event.Type)
+		}
+		return result
+	}
+
+	registry := NewMarketRegistry()
+
+	events := registry.Apply(&models.MarketsResponse{
+		Markets: map[string]*models.Market{
+			"BTC-PERP": market("BTC-PERP", true, false, "1"),
+			"ETH-PERP": market("ETH-PERP", true, false, "0.1"),
+		},
+		BaseResponse: models.BaseResponse{Type: models.Partial},
+	})
+	require.Empty(t, events)
+	require.Len(t, registry.Snapshot(), 2)
+
+	events = registry.Apply(&models.MarketsResponse{
+		Markets: map[string]*models.Market{
+			"BTC-PERP": market("BTC-PERP", false, true, "0.5"),
+			"SOL-PERP": market("SOL-PERP", true, false, "0.01"),
+		},
+		BaseResponse: models.BaseResponse{Type: models.Update},
+	})
+	require.Equal(t, []models.MarketEventType{
+		models.MarketDisabled,
+		models.MarketPostOnlyEnabled,
+		models.MarketPriceIncrementChanged,
+		models.MarketListed,
+	}, eventTypes(events))
+	require.True(t, events[2].Previous.PriceIncrement.Equal(decimal.NewFromInt(1)))
+
+	btc, ok := registry.Get("BTC-PERP")
+	require.True(t, ok)
+	require.True(t, btc.PostOnly)
+
+	events = registry.Apply(&models.MarketsResponse{
+		Markets: map[string]*models.Market{
+			"BTC-PERP": market("BTC-PERP", false, true, "0.5"),
+			"SOL-PERP": market("SOL-PERP", true, false, "0.01"),
+		},
+		BaseResponse: models.BaseResponse{Type: models.Partial},
+	})
+	require.Equal(t, []models.MarketEventType{models.MarketDelisted}, eventTypes(events))
+	require.Equal(t, "ETH-PERP", events[0].Market.Name)
+
+	_, ok = registry.Get("ETH-PERP")
+	require.False(t, ok)
+}
@@ -0,0 +1,300 @@
+package goftx
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	metadataRefreshInterval = time.Minute
+	metadataUnknownTTL      = time.Second * 30
+)
+
+var errUnknownMarket = errors.New("unknown market")
+
+type MetadataCacheOption func(c *MetadataCache)
+
+// WithMetadataRefreshInterval sets how long the metadata is fresh. It is refreshed with REST
+// on this schedule after Start and on lookups of stale metadata.
+func WithMetadataRefreshInterval(interval time.Duration) MetadataCacheOption {
+	return func(c *MetadataCache) {
+		c.refreshInterval = interval
+	}
+}
+
+// WithMetadataUnknownTTL sets how long a market that is missing after a refresh is reported unknown
+// without refreshing again, so lookups of a wrong market do not cost a refresh each.
+func WithMetadataUnknownTTL(ttl time.Duration) MetadataCacheOption {
+	return func(c *MetadataCache) {
+		c.unknownTTL = ttl
+	}
+}
+
+// WithMetadataStream makes Start follow the markets channel, so listings and changes of
+// flags and increments are applied as they happen instead of on the next refresh.
+func WithMetadataStream() MetadataCacheOption {
+	return func(c *MetadataCache) {
+		c.stream = true
+	}
+}
+
+// MetadataCache keeps the metadata of every market keyed by market name, merged from
+// Markets.GetMarkets and Futures.GetFutures.
+type MetadataCache struct {
+	client          *Client
+	refreshInterval time.Duration
+	unknownTTL      time.Duration
+	stream          bool
+
+	refreshMu   sync.Mutex
+	mu          sync.RWMutex
+	markets     map[string]*models.MarketMetadata
+	futures     map[string]*models.Future
+	refreshedAt time.Time
+	// unknown keeps when markets were found missing by a refresh.
+	unknown map[string]time.Time
+}
+
+func NewMetadataCache(client *Client, opts ...MetadataCacheOption) *MetadataCache {
+	c := &MetadataCache{
+		client:          client,
+		refreshInterval: metadataRefreshInterval,
+		unknownTTL:      metadataUnknownTTL,
+		markets:         make(map[string]*models.MarketMetadata),
+		futures:         make(map[string]*models.Future),
+		unknown:         make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Start loads the metadata and keeps it fresh until ctx is done. Without Start the metadata
+// is loaded and refreshed on lookups only.
+func (c *MetadataCache) Start(ctx context.Context) error {
+	err := c.Refresh()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var marketsC chan event
+	if c.stream {
+		ctx, marketsC, err = c.client.Stream.serve(ctx, models.WSRequest{
+			Channel: models.MarketsChannel,
+			Op:      models.Subscribe,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	go c.run(ctx, marketsC)
+
+	return nil
+}
+
+func (c *MetadataCache) run(ctx context.Context, marketsC chan event) {
+	var refreshTickerC <-chan time.Time
+	if c.refreshInterval > 0 {
+		refreshTicker := time.NewTicker(c.refreshInterval)
+		defer refreshTicker.Stop()
+		refreshTickerC = refreshTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-marketsC:
+			if !ok {
+				marketsC = nil
+				continue
+			}
+			if ev.markets != nil {
+				c.applyMarkets(ev.markets)
+			}
+		case <-refreshTickerC:
+			if err := c.Refresh(); err != nil {
+				c.client.Stream.printf("refresh metadata: %+v", err)
+			}
+		}
+	}
+}
+
+// Refresh reloads markets and futures with REST.
+func (c *MetadataCache) Refresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	return c.refresh()
+}
+
+func (c *MetadataCache) refresh() error {
+	markets, err := c.client.Markets.GetMarkets()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	futures, err := c.client.Futures.GetFutures()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.futures = make(map[string]*models.Future, len(futures))
+	for _, future := range futures {
+		c.futures[future.Name] = future
+	}
+	c.markets = make(map[string]*models.MarketMetadata, len(markets))
+	for _, market := range markets {
+		metadata := models.NewMarketMetadata(*market, c.futures[market.Name])
+		c.markets[market.Name] = &metadata
+	}
+	c.refreshedAt = time.Now()
+	for name, missingAt := range c.unknown {
+		if c.refreshedAt.Sub(missingAt) > c.unknownTTL {
+			delete(c.unknown, name)
+		}
+	}
+
+	return nil
+}
+
+// applyMarkets merges a markets channel message, a partial replaces every market and is a refresh.
+func (c *MetadataCache) applyMarkets(response *models.MarketsResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if response.Type == models.Partial {
+		c.markets = make(map[string]*models.MarketMetadata, len(response.Markets))
+		c.refreshedAt = time.Now()
+	}
+	for name, market := range response.Markets {
+		if market == nil {
+			continue
+		}
+		m := *market
+		if m.Name == "" {
+			m.Name = name
+		}
+		metadata := models.NewMarketMetadata(m, c.futures[name])
+		c.markets[name] = &metadata
+		delete(c.unknown, name)
+	}
+}
+
+// Get returns the cached metadata of a market.
+func (c *MetadataCache) Get(name string) (*models.MarketMetadata, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	metadata, ok := c.markets[name]
+	if !ok {
+		return nil, false
+	}
+	m := *metadata
+	return &m, true
+}
+
+// Lookup returns the metadata of a market, refreshing it first if it is stale or the market is unknown.
+// A market still missing after a refresh is reported unknown without a refresh for the unknown TTL.
+func (c *MetadataCache) Lookup(name string) (*models.MarketMetadata, error) {
+	metadata, ok := c.Get(name)
+	if ok && !c.IsStale() {
+		return metadata, nil
+	}
+	if !ok && c.isUnknown(name) {
+		return nil, errors.Wrapf(errUnknownMarket, "market %v", name)
+	}
+
+	c.refreshMu.Lock()
+	// another lookup could have refreshed it while waiting
+	metadata, ok = c.Get(name)
+	refreshed := false
+	if (!ok && !c.isUnknown(name)) || c.IsStale() {
+		err := c.refresh()
+		if err != nil {
+			c.refreshMu.Unlock()
+			return nil, errors.WithStack(err)
+		}
+		refreshed = true
+		metadata, ok = c.Get(name)
+	}
+	c.refreshMu.Unlock()
+
+	if !ok {
+		if refreshed {
+			c.mu.Lock()
+			c.unknown[name] = time.Now()
+			c.mu.Unlock()
+		}
+		return nil, errors.Wrapf(errUnknownMarket, "market %v", name)
+	}
+	return metadata, nil
+}
+
+// isUnknown reports whether a fresh refresh found the market missing within the unknown TTL.
+func (c *MetadataCache) isUnknown(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	missingAt, ok := c.unknown[name]
+	return ok && time.Since(missingAt) <= c.unknownTTL
+}
+
+func (c *MetadataCache) IsStale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.refreshedAt.IsZero() || (c.refreshInterval > 0 && time.Since(c.refreshedAt) > c.refreshInterval)
+}
+
+// All returns the metadata of every market sorted by name.
+func (c *MetadataCache) All() []*models.MarketMetadata {
+	return c.filter(func(metadata *models.MarketMetadata) bool {
+		return true
+	})
+}
+
+// ByCurrency returns the markets of base and quote currencies, an empty currency matches any.
+// Futures have no currencies and are never returned.
+func (c *MetadataCache) ByCurrency(base, quote string) []*models.MarketMetadata {
+	return c.filter(func(metadata *models.MarketMetadata) bool {
+		if metadata.BaseCurrency == "" && metadata.QuoteCurrency == "" {
+			return false
+		}
+		return (base == "" || metadata.BaseCurrency == base) && (quote == "" || metadata.QuoteCurrency == quote)
+	})
+}
+
+// ByUnderlying returns the futures of underlying.
+func (c *MetadataCache) ByUnderlying(underlying string) []*models.MarketMetadata {
+	return c.filter(func(metadata *models.MarketMetadata) bool {
+		return metadata.Underlying == underlying
+	})
+}
+
+func (c *MetadataCache) filter(match func(metadata *models.MarketMetadata) bool) []*models.MarketMetadata {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var result []*models.MarketMetadata
+	for _, metadata := range c.markets {
+		if match(metadata) {
+			m := *metadata
+			result = append(result, &m)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,142 @@
+package goftx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestMetadataCache(t *testing.T) {
+	expiry := time.Date(2021, 3, 26, 3, 0, 0, 0, time.UTC)
+	var refreshes int64
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /markets": func(r *http.Request) (interface{}, error) {
+			atomic.AddInt64(&refreshes, 1)
+			return []models.Market{
+				{Name: "BTC-PERP", Type: "future", Underlying: "BTC", PriceIncrement: decimal.NewFromInt(1), SizeIncrement: decimal.RequireFromString("0.001")},
+				{Name: "BTC-0326", Type: "future", Underlying: "BTC", PriceIncrement: decimal.NewFromInt(1), SizeIncrement: decimal.RequireFromString("0.001")},
+				{Name: "BTC/USD", Type: "spot", BaseCurrency: "BTC", QuoteCurrency: "USD", PriceIncrement: decimal.NewFromInt(1)},
+				{Name: "ETH/USD", Type: "spot", BaseCurrency: "ETH", QuoteCurrency: "USD", PriceIncrement: decimal.RequireFromString("0.1")},
+				{Name: "ETH/BTC", Type: "spot", BaseCurrency: "ETH", QuoteCurrency: "BTC", PriceIncrement: decimal.RequireFromString("0.00001")},
+			}, nil
+		},
+		"GET /futures": func(r *http.Request) (interface{}, error) {
+			return []models.Future{
+				{Name: "BTC-PERP", Type: models.Perpetual, Perpetual: true, Underlying: "BTC", UpperBound: decimal.NewFromInt(10000)},
+				{Name: "BTC-0326", Type: models.TypeFuture, Underlying: "BTC", Expiry: expiry},
+			}, nil
+		},
+	})
+	defer api.Close()
+
+	connC := make(chan *websocket.Conn, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe {
+			connC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := NewMetadataCache(ftx, WithMetadataStream(), WithMetadataRefreshInterval(time.Hour))
+	require.NoError(t, cache.Start(ctx))
+
+	perp, ok := cache.Get("BTC-PERP")
+	require.True(t, ok)
+	require.True(t, perp.IsFuture())
+	require.True(t, perp.Perpetual)
+	require.True(t, perp.UpperBound.Equal(decimal.NewFromInt(10000)))
+
+	quarterly, err := cache.Lookup("BTC-0326")
+	require.NoError(t, err)
+	require.Equal(t, models.TypeFuture, quarterly.FutureType)
+	require.True(t, quarterly.Expiry.Equal(expiry))
+
+	spot, ok := cache.Get("ETH/USD")
+	require.True(t, ok)
+	require.False(t, spot.IsFuture())
+
+	names := func(markets []*models.MarketMetadata) []string {
+		var result []string
+		for _, market := range markets {
+			result = append(result, market.Name)
+		}
+		return result
+	}
+	require.Equal(t, []string{"ETH/BTC", "ETH/USD"}, names(cache.ByCurrency("ETH", "")))
+	require.Equal(t, []string{"BTC/USD", "ETH/USD"}, names(cache.ByCurrency("", "USD")))
+	require.Equal(t, []string{"BTC-0326", "BTC-PERP"}, names(cache.ByUnderlying("BTC")))
+	require.Len(t, cache.All(), 5)
+
+	_, err = cache.Lookup("FOO/USD")
+	require.Error(t, err)
+	require.EqualValues(t, 2, atomic.LoadInt64(&refreshes))
+	// an unknown market is not refreshed again within the unknown TTL
+	_, err = cache.Lookup("FOO/USD")
+	require.Error(t, err)
+	require.EqualValues(t, 2, atomic.LoadInt64(&refreshes))
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-connC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no subscription")
+	}
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"channel": models.MarketsChannel,
+		"type":    models.Update,
+		"data": map[string]interface{}{
+			"data": map[string]interface{}{
+				"BTC-PERP": map[string]interface{}{"name": "BTC-PERP", "type": "future", "underlying": "BTC", "priceIncrement": 0.5},
+				"SOL/USD":  map[string]interface{}{"name": "SOL/USD", "type": "spot", "baseCurrency": "SOL", "quoteCurrency": "USD"},
+			},
+		},
+	}))
+
+	require.Eventually(t, func() bool {
+		_, ok := cache.Get("SOL/USD")
+		return ok
+	}, 2*time.Second, 10*time.Millisecond)
+
+	perp, ok = cache.Get("BTC-PERP")
+	require.True(t, ok)
+	require.Equal(t, "0.5", perp.PriceIncrement.String())
+	// the future information survives updates from the markets channel
+	require.True(t, perp.Perpetual)
+	require.EqualValues(t, 2, atomic.LoadInt64(&refreshes))
+
+	// a snapshot of the markets channel is a refresh
+	cache.mu.RLock()
+	refreshedAt := cache.refreshedAt
+	cache.mu.RUnlock()
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"channel": models.MarketsChannel,
+		"type":    models.Partial,
+		"data": map[string]interface{}{
+			"data": map[string]interface{}{
+				"FOO/USD": map[string]interface{}{"name": "FOO/USD", "type": "spot", "baseCurrency": "FOO", "quoteCurrency": "USD"},
+			},
+		},
+	}))
+	require.Eventually(t, func() bool {
+		cache.mu.RLock()
+		defer cache.mu.RUnlock()
+		return cache.refreshedAt.After(refreshedAt)
+	}, 2*time.Second, 10*time.Millisecond)
+	_, err = cache.Lookup("FOO/USD")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, atomic.LoadInt64(&refreshes))
+}
@@ -0,0 +1,302 @@
+package goftx
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// DefaultLatencyBuckets are the upper bounds of latency histograms of MemoryMetrics.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// StreamMetrics receives measurements of the feed of a Stream.
+// Methods are called from the read loops of all connections and must be safe for concurrent use.
+type StreamMetrics interface {
+	MessageReceived(channel models.Channel, market string, receivedAt time.Time)
+	// MessageDropped is called for data messages that are not delivered to the subscriber.
+	MessageDropped(channel models.Channel, market string)
+	DecodeError(channel models.Channel)
+	ReconnectAttempt(channel models.Channel)
+	ReconnectSuccess(channel models.Channel)
+	// Latency is the local receive time minus the exchange time of the message, corrected by the server time diff.
+	Latency(channel models.Channel, market string, latency time.Duration)
+}
+
+// LatencyHistogram counts latencies by bucket. Counts[i] holds latencies up to Bounds[i],
+// the last element of Counts holds the ones above the last bound.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+}
+
+func newLatencyHistogram(bounds []time.Duration) LatencyHistogram {
+	return LatencyHistogram{
+		Bounds: bounds,
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *LatencyHistogram) observe(latency time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool {
+		return latency <= h.Bounds[i]
+	})
+	h.Counts[i]++
+	h.Count++
+	h.Sum += latency
+}
+
+// Mean returns the average latency or zero if nothing was observed.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+func (h LatencyHistogram) copy() LatencyHistogram {
+	counts := make([]int64, len(h.Counts))
+	copy(counts, h.Counts)
+	h.Counts = counts
+	return h
+}
+
+// FeedMetrics are the metrics of a single channel and market, market is empty for channels without markets.
+type FeedMetrics struct {
+	Channel          models.Channel
+	Market           string
+	Received         int64
+	Dropped          int64
+	LastMessage      time.Time
+	SinceLastMessage time.Duration
+	Latency          LatencyHistogram
+}
+
+type MetricsSnapshot struct {
+	// Feeds are sorted by channel and market.
+	Feeds              []FeedMetrics
+	DecodeErrors       map[models.Channel]int64
+	ReconnectAttempts  map[models.Channel]int64
+	ReconnectSuccesses map[models.Channel]int64
+	LastMessage        time.Time
+	SinceLastMessage   time.Duration
+}
+
+// Feed returns the metrics of channel and market.
+func (s MetricsSnapshot) Feed(channel models.Channel, market string) (FeedMetrics, bool) {
+	for _, feed := range s.Feeds {
+		if feed.Channel == channel && feed.Market == market {
+			return feed, true
+		}
+	}
+	return FeedMetrics{}, false
+}
+
+// MemoryMetrics is the default StreamMetrics of a Stream, it keeps counters in memory to be scraped with Snapshot.
+type MemoryMetrics struct {
+	mu                 sync.Mutex
+	buckets            []time.Duration
+	feeds              map[string]*FeedMetrics
+	decodeErrors       map[models.Channel]int64
+	reconnectAttempts  map[models.Channel]int64
+	reconnectSuccesses map[models.Channel]int64
+	lastMessage        time.Time
+}
+
+// NewMemoryMetrics creates metrics with latency histograms of the given upper bounds, DefaultLatencyBuckets if none.
+func NewMemoryMetrics(buckets ...time.Duration) *MemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	bounds := make([]time.Duration, len(buckets))
+	copy(bounds, buckets)
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i] < bounds[j]
+	})
+
+	return &MemoryMetrics{
+		buckets:            bounds,
+		feeds:              make(map[string]*FeedMetrics),
+		decodeErrors:       make(map[models.Channel]int64),
+		reconnectAttempts:  make(map[models.Channel]int64),
+		reconnectSuccesses: make(map[models.Channel]int64),
+	}
+}
+
+func (m *MemoryMetrics) feed(channel models.Channel, market string) *FeedMetrics {
+	key := feedKey(channel, market)
+	feed, ok := m.feeds[key]
+	if !ok {
+		feed = &FeedMetrics{
+			Channel: channel,
+			Market:  market,
+			Latency: newLatencyHistogram(m.buckets),
+		}
+		m.feeds[key] = feed
+	}
+	return feed
+}
+
+func (m *MemoryMetrics) MessageReceived(channel models.Channel, market string, receivedAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed := m.feed(channel, market)
+	feed.Received++
+	feed.LastMessage = receivedAt
+	if receivedAt.After(m.lastMessage) {
+		m.lastMessage = receivedAt
+	}
+}
+
+func (m *MemoryMetrics) MessageDropped(channel models.Channel, market string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.feed(channel, market).Dropped++
+}
+
+func (m *MemoryMetrics) DecodeError(channel models.Channel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.decodeErrors[channel]++
+}
+
+func (m *MemoryMetrics) ReconnectAttempt(channel models.Channel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reconnectAttempts[channel]++
+}
+
+func (m *MemoryMetrics) ReconnectSuccess(channel models.Channel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reconnectSuccesses[channel]++
+}
+
+func (m *MemoryMetrics) Latency(channel models.Channel, market string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.feed(channel, market).Latency.observe(latency)
+}
+
+// Snapshot returns a copy of the current metrics.
+func (m *MemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	snapshot := MetricsSnapshot{
+		Feeds:              make([]FeedMetrics, 0, len(m.feeds)),
+		DecodeErrors:       copyCounters(m.decodeErrors),
+		ReconnectAttempts:  copyCounters(m.reconnectAttempts),
+		ReconnectSuccesses: copyCounters(m.reconnectSuccesses),
+		LastMessage:        m.lastMessage,
+	}
+	if !m.lastMessage.IsZero() {
+		snapshot.SinceLastMessage = now.Sub(m.lastMessage)
+	}
+
+	for _, feed := range m.feeds {
+		result := *feed
+		result.Latency = feed.Latency.copy()
+		if !feed.LastMessage.IsZero() {
+			result.SinceLastMessage = now.Sub(feed.LastMessage)
+		}
+		snapshot.Feeds = append(snapshot.Feeds, result)
+	}
+	sort.Slice(snapshot.Feeds, func(i, j int) bool {
+		if snapshot.Feeds[i].Channel != snapshot.Feeds[j].Channel {
+			return snapshot.Feeds[i].Channel < snapshot.Feeds[j].Channel
+		}
+		return snapshot.Feeds[i].Market < snapshot.Feeds[j].Market
+	})
+
+	return snapshot
+}
+
+func copyCounters(counters map[models.Channel]int64) map[models.Channel]int64 {
+	result := make(map[models.Channel]int64, len(counters))
+	for channel, count := range counters {
+		result[channel] = count
+	}
+	return result
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) MessageReceived(models.Channel, string, time.Time) {}
+func (noopMetrics) MessageDropped(models.Channel, string)             {}
+func (noopMetrics) DecodeError(models.Channel)                        {}
+func (noopMetrics) ReconnectAttempt(models.Channel)                   {}
+func (noopMetrics) ReconnectSuccess(models.Channel)                   {}
+func (noopMetrics) Latency(models.Channel, string, time.Duration)     {}
+
+// observeLatency reports the latency of every exchange timestamp of the event.
+func observeLatency(metrics StreamMetrics, header frameHeader, ev event, localTime time.Time) {
+	observe := func(exchangeTime time.Time) {
+		if exchangeTime.IsZero() {
+			return
+		}
+		metrics.Latency(header.Channel, header.Market, localTime.Sub(exchangeTime))
+	}
+
+	switch {
+	case ev.ticker != nil:
+		observe(ev.ticker.Time.Time)
+	case ev.book != nil:
+		observe(ev.book.Time.Time)
+	case ev.grouped != nil:
+		observe(ev.grouped.Time.Time)
+	case ev.trades != nil:
+		for _, trade := range ev.trades.Trades {
+			observe(trade.Time)
+		}
+	}
+}
+
+// SetMetrics replaces the metrics of the stream for subscriptions started afterwards, nil disables metrics.
+func (s *Stream) SetMetrics(metrics StreamMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metrics = metrics
+}
+
+// Metrics returns the metrics of the stream, a *MemoryMetrics unless replaced by SetMetrics.
+func (s *Stream) Metrics() StreamMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.metrics
+}
+
+func (s *Stream) streamMetrics() StreamMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.metrics == nil {
+		return noopMetrics{}
+	}
+	return s.metrics
+}
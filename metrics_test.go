@@ -0,0 +1,87 @@
+package goftx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	metrics := NewMemoryMetrics(100*time.Millisecond, 10*time.Millisecond)
+	for _, latency := range []time.Duration{-time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		metrics.Latency(models.TickerChannel, "BTC-PERP", latency)
+	}
+
+	feed, ok := metrics.Snapshot().Feed(models.TickerChannel, "BTC-PERP")
+	require.True(t, ok)
+	require.Equal(t, []time.Duration{10 * time.Millisecond, 100 * time.Millisecond}, feed.Latency.Bounds)
+	require.Equal(t, []int64{2, 1, 1}, feed.Latency.Counts)
+	require.EqualValues(t, 4, feed.Latency.Count)
+	require.Equal(t, 264750*time.Microsecond, feed.Latency.Mean())
+}
+
+func TestStream_Metrics(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data":    "malformed",
+		})
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data": map[string]interface{}{
+				"bid":  9000,
+				"ask":  9001,
+				"time": float64(time.Now().Add(-200*time.Millisecond).UnixNano()) / float64(time.Second),
+			},
+		})
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.Stream.SetReconnectionInterval(10 * time.Millisecond)
+	metrics := NewMemoryMetrics()
+	ftx.Stream.SetMetrics(metrics)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data, err := ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	receive := func() {
+		select {
+		case msg := <-data:
+			require.Equal(t, "BTC-PERP", msg.Symbol)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no ticker received")
+		}
+	}
+	receive()
+	// the first reconnect attempt is rejected
+	ts.dropConnections(1)
+	receive()
+
+	snapshot := metrics.Snapshot()
+	feed, ok := snapshot.Feed(models.TickerChannel, "BTC-PERP")
+	require.True(t, ok)
+	require.EqualValues(t, 2, feed.Received)
+	require.EqualValues(t, 2, feed.Latency.Count)
+	require.True(t, feed.Latency.Mean() >= 200*time.Millisecond)
+	require.False(t, feed.LastMessage.IsZero())
+	require.False(t, snapshot.LastMessage.IsZero())
+	require.EqualValues(t, 2, snapshot.DecodeErrors[models.TickerChannel])
+	require.EqualValues(t, 2, snapshot.ReconnectAttempts[models.TickerChannel])
+	require.EqualValues(t, 1, snapshot.ReconnectSuccesses[models.TickerChannel])
+}
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const maxFixedPlaces = 18
+
+var bigTen = big.NewInt(10)
+
+// FixedScale holds the number of decimal places of prices and sizes of a market.
+// A fixed value is the decimal multiplied by 10^places and stored as int64.
+type FixedScale struct {
+	Price int32
+	Size  int32
+}
+
+// NewFixedScale derives the scale from the increments of a market, so every valid price and size is an integer.
+func NewFixedScale(priceIncrement, sizeIncrement decimal.Decimal) FixedScale {
+	return FixedScale{
+		Price: decimalPlaces(priceIncrement),
+		Size:  decimalPlaces(sizeIncrement),
+	}
+}
+
+func (m Market) FixedScale() FixedScale {
+	return NewFixedScale(m.PriceIncrement, m.SizeIncrement)
+}
+
+func decimalPlaces(d decimal.Decimal) int32 {
+	places := -d.Exponent()
+	if places <= 0 {
+		return 0
+	}
+
+	coefficient := d.Coefficient()
+	remainder := new(big.Int)
+	for places > 0 && coefficient.Sign() != 0 {
+		quotient, rem := new(big.Int).QuoRem(coefficient, bigTen, remainder)
+		if rem.Sign() != 0 {
+			break
+		}
+		coefficient = quotient
+		places--
+	}
+	return places
+}
+
+// ToFixed converts d into a fixed value with the given decimal places.
+// It fails rather than rounds if d has more places or does not fit into int64.
+func ToFixed(d decimal.Decimal, places int32) (int64, error) {
+	if places < 0 || places > maxFixedPlaces {
+		return 0, errors.Errorf("places %v is out of range", places)
+	}
+
+	value := d.Coefficient()
+	shift := d.Exponent() + places
+	if shift >= 0 {
+		value.Mul(value, new(big.Int).Exp(bigTen, big.NewInt(int64(shift)), nil))
+	} else {
+		remainder := new(big.Int)
+		value.QuoRem(value, new(big.Int).Exp(bigTen, big.NewInt(int64(-shift)), nil), remainder)
+		if remainder.Sign() != 0 {
+			return 0, errors.Errorf("%v has more than %v decimal places", d, places)
+		}
+	}
+	if !value.IsInt64() {
+		return 0, errors.Errorf("%v overflows fixed value with %v decimal places", d, places)
+	}
+	return value.Int64(), nil
+}
+
+// FromFixed converts a fixed value with the given decimal places back into decimal.
+func FromFixed(value int64, places int32) decimal.Decimal {
+	return decimal.New(value, -places)
+}
+
+func (s FixedScale) ToPrice(price decimal.Decimal) (int64, error) {
+	return ToFixed(price, s.Price)
+}
+
+func (s FixedScale) ToSize(size decimal.Decimal) (int64, error) {
+	return ToFixed(size, s.Size)
+}
+
+func (s FixedScale) FromPrice(price int64) decimal.Decimal {
+	return FromFixed(price, s.Price)
+}
+
+func (s FixedScale) FromSize(size int64) decimal.Decimal {
+	return FromFixed(size, s.Size)
+}
+
+// FixedLevel is a price level of a FixedOrderBook.
+type FixedLevel struct {
+	Price int64
+	Size  int64
+}
+
+// FixedOrderBook is an OrderBook with prices and sizes scaled by Scale.
+// Bids are sorted by descending and asks by ascending price.
+type FixedOrderBook struct {
+	Scale    FixedScale
+	Bids     []FixedLevel
+	Asks     []FixedLevel
+	Checksum int64
+	Time     FTXTime
+}
+
+// OrderBook converts book into the fixed representation.
+func (s FixedScale) OrderBook(book OrderBook) (FixedOrderBook, error) {
+	bids, err := s.levels(book.Bids)
+	if err != nil {
+		return FixedOrderBook{}, errors.WithStack(err)
+	}
+	asks, err := s.levels(book.Asks)
+	if err != nil {
+		return FixedOrderBook{}, errors.WithStack(err)
+	}
+
+	return FixedOrderBook{
+		Scale:    s,
+		Bids:     bids,
+		Asks:     asks,
+		Checksum: book.Checksum,
+		Time:     book.Time,
+	}, nil
+}
+
+func (s FixedScale) levels(levels [][]decimal.Decimal) ([]FixedLevel, error) {
+	result := make([]FixedLevel, 0, len(levels))
+	for _, level := range levels {
+		if len(level) < 2 {
+			continue
+		}
+		price, err := s.ToPrice(level[0])
+		if err != nil {
+			return nil, err
+		}
+		size, err := s.ToSize(level[1])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, FixedLevel{Price: price, Size: size})
+	}
+	return result, nil
+}
+
+// Decimal converts the book back into OrderBook.
+func (ob *FixedOrderBook) Decimal() OrderBook {
+	levels := func(levels []FixedLevel) [][]decimal.Decimal {
+		result := make([][]decimal.Decimal, 0, len(levels))
+		for _, level := range levels {
+			result = append(result, []decimal.Decimal{ob.Scale.FromPrice(level.Price), ob.Scale.FromSize(level.Size)})
+		}
+		return result
+	}
+
+	return OrderBook{
+		Bids:     levels(ob.Bids),
+		Asks:     levels(ob.Asks),
+		Checksum: ob.Checksum,
+		Time:     ob.Time,
+	}
+}
+
+// Apply merges an orderbook channel message into the book like OrderBook.Apply.
+// Both books must have the same scale.
+func (ob *FixedOrderBook) Apply(responseType ResponseType, book FixedOrderBook) {
+	if responseType == Partial {
+		ob.Bids = copyFixedLevels(book.Bids)
+		ob.Asks = copyFixedLevels(book.Asks)
+	} else {
+		ob.Bids = mergeFixedLevels(ob.Bids, book.Bids, true)
+		ob.Asks = mergeFixedLevels(ob.Asks, book.Asks, false)
+	}
+	ob.Scale = book.Scale
+	ob.Checksum = book.Checksum
+	ob.Time = book.Time
+}
+
+// BestBid returns the highest bid or false if there are no bids.
+func (ob *FixedOrderBook) BestBid() (FixedLevel, bool) {
+	if len(ob.Bids) == 0 {
+		return FixedLevel{}, false
+	}
+	return ob.Bids[0], true
+}
+
+// BestAsk returns the lowest ask or false if there are no asks.
+func (ob *FixedOrderBook) BestAsk() (FixedLevel, bool) {
+	if len(ob.Asks) == 0 {
+		return FixedLevel{}, false
+	}
+	return ob.Asks[0], true
+}
+
+func copyFixedLevels(levels []FixedLevel) []FixedLevel {
+	result := make([]FixedLevel, 0, len(levels))
+	for _, level := range levels {
+		if level.Size == 0 {
+			continue
+		}
+		result = append(result, level)
+	}
+	return result
+}
+
+func mergeFixedLevels(levels, updates []FixedLevel, descending bool) []FixedLevel {
+	for _, update := range updates {
+		i := sort.Search(len(levels), func(i int) bool {
+			if descending {
+				return levels[i].Price <= update.Price
+			}
+			return levels[i].Price >= update.Price
+		})
+		found := i < len(levels) && levels[i].Price == update.Price
+
+		switch {
+		case update.Size == 0 && found:
+			levels = append(levels[:i], levels[i+1:]...)
+		case update.Size == 0:
+		case found:
+			levels[i] = update
+		default:
+			levels = append(levels, FixedLevel{})
+			copy(levels[i+1:], levels[i:])
+			levels[i] = update
+		}
+	}
+	return levels
+}
+
+// FixedTicker is a Ticker with prices and sizes scaled by Scale.
+type FixedTicker struct {
+	Scale   FixedScale
+	Bid     int64
+	Ask     int64
+	BidSize int64
+	AskSize int64
+	Last    int64
+	Time    FTXTime
+}
+
+// Ticker converts ticker into the fixed representation.
+func (s FixedScale) Ticker(ticker Ticker) (FixedTicker, error) {
+	result := FixedTicker{Scale: s, Time: ticker.Time}
+
+	var err error
+	for _, field := range []struct {
+		value  decimal.Decimal
+		dest   *int64
+		places int32
+	}{
+		{value: ticker.Bid, dest: &result.Bid, places: s.Price},
+		{value: ticker.Ask, dest: &result.Ask, places: s.Price},
+		{value: ticker.Last, dest: &result.Last, places: s.Price},
+		{value: ticker.BidSize, dest: &result.BidSize, places: s.Size},
+		{value: ticker.AskSize, dest: &result.AskSize, places: s.Size},
+	} {
+		*field.dest, err = ToFixed(field.value, field.places)
+		if err != nil {
+			return FixedTicker{}, errors.WithStack(err)
+		}
+	}
+
+	return result, nil
+}
+
+// Decimal converts the ticker back into Ticker.
+func (t *FixedTicker) Decimal() Ticker {
+	return Ticker{
+		Bid:     t.Scale.FromPrice(t.Bid),
+		Ask:     t.Scale.FromPrice(t.Ask),
+		BidSize: t.Scale.FromSize(t.BidSize),
+		AskSize: t.Scale.FromSize(t.AskSize),
+		Last:    t.Scale.FromPrice(t.Last),
+		Time:    t.Time,
+	}
+}
+
+// FixedTrade is a Trade with price and size scaled by Scale.
+type FixedTrade struct {
+	Scale       FixedScale
+	ID          int64
+	Liquidation bool
+	Price       int64
+	Side        string
+	Size        int64
+	Time        time.Time
+}
+
+// Trade converts trade into the fixed representation.
+func (s FixedScale) Trade(trade Trade) (FixedTrade, error) {
+	price, err := s.ToPrice(trade.Price)
+	if err != nil {
+		return FixedTrade{}, errors.WithStack(err)
+	}
+	size, err := s.ToSize(trade.Size)
+	if err != nil {
+		return FixedTrade{}, errors.WithStack(err)
+	}
+
+	return FixedTrade{
+		Scale:       s,
+		ID:          trade.ID,
+		Liquidation: trade.Liquidation,
+		Price:       price,
+		Side:        trade.Side,
+		Size:        size,
+		Time:        trade.Time,
+	}, nil
+}
+
+// Decimal converts the trade back into Trade.
+func (t *FixedTrade) Decimal() Trade {
+	return Trade{
+		ID:          t.ID,
+		Liquidation: t.Liquidation,
+		Price:       t.Scale.FromPrice(t.Price),
+		Side:        t.Side,
+		Size:        t.Scale.FromSize(t.Size),
+		Time:        t.Time,
+	}
+}
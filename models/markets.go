@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -29,6 +30,61 @@ type Market struct {
 	ChangeBod             decimal.Decimal `json:"changeBod"`
 }
 
+// RoundPrice rounds price to the price increment toward the passive side,
+// down for buys and up for sells, so a limit order does not become more aggressive.
+func (m Market) RoundPrice(side Side, price decimal.Decimal) decimal.Decimal {
+	return RoundToIncrement(price, m.PriceIncrement, side == Sell)
+}
+
+// FloorSize rounds size down to the size increment.
+func (m Market) FloorSize(size decimal.Decimal) decimal.Decimal {
+	return RoundToIncrement(size, m.SizeIncrement, false)
+}
+
+// RoundToIncrement rounds value to a multiple of increment, up or down. A non-positive increment keeps value.
+func RoundToIncrement(value, increment decimal.Decimal, up bool) decimal.Decimal {
+	if !increment.IsPositive() {
+		return value
+	}
+	steps := value.Div(increment)
+	if up {
+		steps = steps.Ceil()
+	} else {
+		steps = steps.Floor()
+	}
+	return steps.Mul(increment)
+}
+
+// IsMultipleOf reports whether value is a multiple of increment. Every value is a multiple of a non-positive increment.
+func IsMultipleOf(value, increment decimal.Decimal) bool {
+	if !increment.IsPositive() {
+		return true
+	}
+	return value.Mod(increment).IsZero()
+}
+
+type MarketEventType string
+
+const (
+	MarketListed                = MarketEventType("listed")
+	MarketDelisted              = MarketEventType("delisted")
+	MarketEnabled               = MarketEventType("enabled")
+	MarketDisabled              = MarketEventType("disabled")
+	MarketPostOnlyEnabled       = MarketEventType("postOnlyEnabled")
+	MarketPostOnlyDisabled      = MarketEventType("postOnlyDisabled")
+	MarketPriceIncrementChanged = MarketEventType("priceIncrementChanged")
+	MarketSizeIncrementChanged  = MarketEventType("sizeIncrementChanged")
+	MarketMinProvideSizeChanged = MarketEventType("minProvideSizeChanged")
+)
+
+// MarketEvent describes a single change of a market.
+// Previous is nil for listed markets, Market is the last known state for delisted ones.
+type MarketEvent struct {
+	Type     MarketEventType
+	Market   *Market
+	Previous *Market
+}
+
 // The bids and asks are formatted like so:
 // [[best price, size at price], [next next best price, size at price], ...]
 //
@@ -63,6 +119,60 @@ type OrderBook struct {
 	Time     FTXTime             `json:"time"`
 }
 
+// Apply merges an orderbook channel message into the book.
+// A partial replaces the book, an update sets the size of every level and removes levels with zero size.
+func (ob *OrderBook) Apply(responseType ResponseType, book OrderBook) {
+	if responseType == Partial {
+		ob.Bids = copyLevels(book.Bids)
+		ob.Asks = copyLevels(book.Asks)
+	} else {
+		ob.Bids = mergeLevels(ob.Bids, book.Bids, true)
+		ob.Asks = mergeLevels(ob.Asks, book.Asks, false)
+	}
+	ob.Checksum = book.Checksum
+	ob.Time = book.Time
+}
+
+func copyLevels(levels [][]decimal.Decimal) [][]decimal.Decimal {
+	result := make([][]decimal.Decimal, 0, len(levels))
+	for _, level := range levels {
+		if len(level) < 2 || level[1].IsZero() {
+			continue
+		}
+		result = append(result, level)
+	}
+	return result
+}
+
+func mergeLevels(levels, updates [][]decimal.Decimal, descending bool) [][]decimal.Decimal {
+	for _, update := range updates {
+		if len(update) < 2 {
+			continue
+		}
+		price, size := update[0], update[1]
+		i := sort.Search(len(levels), func(i int) bool {
+			if descending {
+				return levels[i][0].LessThanOrEqual(price)
+			}
+			return levels[i][0].GreaterThanOrEqual(price)
+		})
+		found := i < len(levels) && levels[i][0].Equal(price)
+
+		switch {
+		case size.IsZero() && found:
+			levels = append(levels[:i], levels[i+1:]...)
+		case size.IsZero():
+		case found:
+			levels[i] = []decimal.Decimal{price, size}
+		default:
+			levels = append(levels, nil)
+			copy(levels[i+1:], levels[i:])
+			levels[i] = []decimal.Decimal{price, size}
+		}
+	}
+	return levels
+}
+
 type Trade struct {
 	ID          int64           `json:"id"`
 	Liquidation bool            `json:"liquidation"`
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MarketMetadata merges the static information of a market with the one of its future.
+// Bid, Ask and Last of the embedded Market are as of the last refresh and should not be used as prices.
+type MarketMetadata struct {
+	Market
+	FutureType  FutureType
+	Perpetual   bool
+	Expiry      time.Time
+	Expired     bool
+	Description string
+	UpperBound  decimal.Decimal
+	LowerBound  decimal.Decimal
+}
+
+// NewMarketMetadata merges market and future, future is nil for markets which are not futures.
+func NewMarketMetadata(market Market, future *Future) MarketMetadata {
+	metadata := MarketMetadata{Market: market}
+	if future == nil {
+		return metadata
+	}
+
+	if metadata.Underlying == "" {
+		metadata.Underlying = future.Underlying
+	}
+	metadata.FutureType = future.Type
+	metadata.Perpetual = future.Perpetual
+	metadata.Expiry = future.Expiry
+	metadata.Expired = future.Expired
+	metadata.Description = future.Description
+	metadata.UpperBound = future.UpperBound
+	metadata.LowerBound = future.LowerBound
+	return metadata
+}
+
+func (m MarketMetadata) IsFuture() bool {
+	return m.FutureType != ""
+}
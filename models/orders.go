@@ -24,7 +24,14 @@ type Order struct {
 	Ioc           bool            `json:"ioc"`
 	PostOnly      bool            `json:"postOnly"`
 	Future        string          `json:"future"`
-	ClientID      string          `json:"clientId"`
+	ClientID      ClientID        `json:"clientId"`
+}
+
+// ClientID is the ID assigned to an order by the client.
+type ClientID string
+
+func (id ClientID) String() string {
+	return string(id)
 }
 
 type GetOrdersHistoryParams struct {
@@ -90,7 +97,7 @@ type PlaceOrderPayload struct {
 	ReduceOnly *bool           `json:"reduceOnly,omitempty"`
 	IOC        *bool           `json:"ioc,omitempty"`
 	PostOnly   *bool           `json:"postOnly,omitempty"`
-	ClientID   *string         `json:"clientId,omitempty"`
+	ClientID   *ClientID       `json:"clientId,omitempty"`
 }
 
 type PlaceTriggerOrderPayload struct {
@@ -127,7 +134,13 @@ func (t PlaceTriggerOrderPayload) Validate() error {
 type ModifyOrderPayload struct {
 	Price    *decimal.Decimal `json:"price,omitempty"`
 	Size     *decimal.Decimal `json:"size,omitempty"`
-	ClientID *string          `json:"clientId,omitempty"`
+	ClientID *ClientID        `json:"clientId,omitempty"`
+}
+
+// OrderModification is an item of a bulk modification.
+type OrderModification struct {
+	OrderID int64
+	Payload ModifyOrderPayload
 }
 
 type ModifyTriggerOrderPayload struct {
@@ -137,6 +150,72 @@ type ModifyTriggerOrderPayload struct {
 	TrailValue   *decimal.Decimal `json:"trailValue,omitempty"`
 }
 
+type TwapOrder struct {
+	ID                     int64           `json:"id"`
+	Market                 string          `json:"market"`
+	Side                   Side            `json:"side"`
+	Size                   decimal.Decimal `json:"size"`
+	FilledSize             decimal.Decimal `json:"filledSize"`
+	AvgFillPrice           decimal.Decimal `json:"avgFillPrice"`
+	Status                 TwapStatus      `json:"status"`
+	DurationSeconds        int64           `json:"durationSeconds"`
+	RandomizeSize          bool            `json:"randomizeSize"`
+	ReduceOnly             bool            `json:"reduceOnly"`
+	MaxSpread              decimal.Decimal `json:"maxSpread"`
+	MaxIndividualOrderSize decimal.Decimal `json:"maxIndividualOrderSize"`
+	CancelReason           string          `json:"cancelReason"`
+	CreatedAt              time.Time       `json:"createdAt"`
+	EndTime                time.Time       `json:"endTime"`
+}
+
+func (t TwapOrder) Duration() time.Duration {
+	return time.Duration(t.DurationSeconds) * time.Second
+}
+
+type GetTwapOrdersParams struct {
+	Market *string `json:"market"`
+}
+
+type GetTwapOrdersHistoryParams struct {
+	Market    *string `json:"market"`
+	StartTime *int    `json:"start_time"`
+	EndTime   *int    `json:"end_time"`
+	Limit     *int    `json:"limit"`
+}
+
+// PlaceTwapOrderPayload places a TWAP order executed by the exchange with market orders over DurationSeconds.
+type PlaceTwapOrderPayload struct {
+	Market                 string           `json:"market"`
+	Side                   Side             `json:"side"`
+	Size                   decimal.Decimal  `json:"size"`
+	Type                   OrderType        `json:"type"`
+	DurationSeconds        int64            `json:"durationSeconds"`
+	RandomizeSize          *bool            `json:"randomizeSize,omitempty"`
+	ReduceOnly             *bool            `json:"reduceOnly,omitempty"`
+	MaxSpread              *decimal.Decimal `json:"maxSpread,omitempty"`
+	MaxIndividualOrderSize *decimal.Decimal `json:"maxIndividualOrderSize,omitempty"`
+}
+
+func (t PlaceTwapOrderPayload) Validate() error {
+	if t.Market == "" {
+		return errors.New("market is required for twap orders")
+	}
+	if t.Side != Buy && t.Side != Sell {
+		return errors.Errorf("side %v is invalid", t.Side)
+	}
+	if !t.Size.IsPositive() {
+		return errors.New("size of twap orders must be positive")
+	}
+	if t.Type != MarketOrder {
+		return errors.Errorf("twap orders could not be %v orders", t.Type)
+	}
+	if t.DurationSeconds <= 0 {
+		return errors.New("durationSeconds of twap orders must be positive")
+	}
+
+	return nil
+}
+
 type CancelAllOrdersPayload struct {
 	Market                *string `json:"market,omitempty"`
 	ConditionalOrdersOnly *bool   `json:"conditionalOrdersOnly,omitempty"`
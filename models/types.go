@@ -21,12 +21,13 @@ const (
 type Channel string
 
 const (
-	OrderBookChannel = Channel("orderbook")
-	TradesChannel    = Channel("trades")
-	TickerChannel    = Channel("ticker")
-	MarketsChannel   = Channel("markets")
-	FillsChannel     = Channel("fills")
-	OrdersChannel    = Channel("orders")
+	OrderBookChannel        = Channel("orderbook")
+	GroupedOrderBookChannel = Channel("orderbookGrouped")
+	TradesChannel           = Channel("trades")
+	TickerChannel           = Channel("ticker")
+	MarketsChannel          = Channel("markets")
+	FillsChannel            = Channel("fills")
+	OrdersChannel           = Channel("orders")
 )
 
 type Operation string
@@ -35,6 +36,7 @@ const (
 	Subscribe   = Operation("subscribe")
 	UnSubscribe = Operation("unsubscribe")
 	Login       = Operation("login")
+	Ping        = Operation("ping")
 )
 
 type ResponseType string
@@ -46,8 +48,39 @@ const (
 	Info         = ResponseType("info")
 	Partial      = ResponseType("partial")
 	Update       = ResponseType("update")
+	Pong         = ResponseType("pong")
 )
 
+// EventKind tells how a channel message relates to the state built from the previous messages.
+type EventKind string
+
+const (
+	// SnapshotEvent replaces the whole state, sent as partial by orderbook, orderbookGrouped and markets.
+	SnapshotEvent = EventKind("snapshot")
+	// DeltaEvent changes the last snapshot, sent as update by orderbook, orderbookGrouped and markets.
+	DeltaEvent = EventKind("delta")
+	// StateEvent is the complete current state of a single entity, ticker and orders only send updates of this kind.
+	StateEvent = EventKind("state")
+	// RecordEvent is an append-only record, trades and fills only send updates of this kind.
+	// A trades message could carry a batch of several trades.
+	RecordEvent = EventKind("record")
+)
+
+// EventKindOf returns the kind of a partial or update message of channel.
+// Channels unknown to the library are assumed to follow the partial and update semantics of orderbook.
+func EventKindOf(channel Channel, responseType ResponseType) EventKind {
+	switch channel {
+	case TickerChannel, OrdersChannel:
+		return StateEvent
+	case TradesChannel, FillsChannel:
+		return RecordEvent
+	}
+	if responseType == Partial {
+		return SnapshotEvent
+	}
+	return DeltaEvent
+}
+
 type TransferStatus string
 
 const Complete = TransferStatus("complete")
@@ -66,6 +99,13 @@ const (
 	Buy  = Side("buy")
 )
 
+func (s Side) Opposite() Side {
+	if s == Buy {
+		return Sell
+	}
+	return Buy
+}
+
 type Status string
 
 const (
@@ -74,6 +114,14 @@ const (
 	Closed = Status("closed")
 )
 
+type TwapStatus string
+
+const (
+	TwapRunning   = TwapStatus("running")
+	TwapCompleted = TwapStatus("completed")
+	TwapCancelled = TwapStatus("cancelled")
+)
+
 type TriggerOrderType string
 
 const (
@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 
 	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 )
 
 type BaseResponse struct {
 	Type   ResponseType
+	Kind   EventKind
 	Symbol string
+	// SubAccount is the subaccount a private channel response came from, empty for the main account.
+	SubAccount string
 }
 
 type TickerResponse struct {
@@ -21,13 +25,41 @@ type TradesResponse struct {
 	BaseResponse
 }
 
+// TradeResponse is a single trade of a trades message, BatchIndex and BatchSize keep the boundaries of the message.
 type TradeResponse struct {
 	Trade
+	BatchIndex int
+	BatchSize  int
 	BaseResponse
 }
 
+// OrderBookResponse is a snapshot of the book if IsSnapshot is set, otherwise the levels changed since the previous message.
 type OrderBookResponse struct {
 	OrderBook
+	IsSnapshot bool
+	BaseResponse
+}
+
+type GroupedOrderBookResponse struct {
+	OrderBook
+	Grouping   decimal.Decimal
+	IsSnapshot bool
+	BaseResponse
+}
+
+type FixedTickerResponse struct {
+	FixedTicker
+	BaseResponse
+}
+
+type FixedTradeResponse struct {
+	FixedTrade
+	BaseResponse
+}
+
+type FixedOrderBookResponse struct {
+	FixedOrderBook
+	IsSnapshot bool
 	BaseResponse
 }
 
@@ -36,16 +68,54 @@ type FillResponse struct {
 	BaseResponse
 }
 
+// OrderResponse is the current state of an order, Delta is its change since the previous message of the order.
 type OrderResponse struct {
 	Order
+	Delta OrderDelta
+	BaseResponse
+}
+
+// OrderDelta is the change of an order against the previous message of the same order in a subscription.
+type OrderDelta struct {
+	// First is set for the first message of the order, the previous state is then empty.
+	First bool
+	// FilledSize is the size filled since the previous message.
+	FilledSize     decimal.Decimal
+	PreviousStatus Status
+	StatusChanged  bool
+}
+
+// NewOrderDelta computes the delta of order against its previous state, previous is nil for an order seen first.
+func NewOrderDelta(previous *Order, order Order) OrderDelta {
+	if previous == nil {
+		return OrderDelta{
+			First:         true,
+			FilledSize:    order.FilledSize,
+			StatusChanged: true,
+		}
+	}
+
+	return OrderDelta{
+		FilledSize:     order.FilledSize.Sub(previous.FilledSize),
+		PreviousStatus: previous.Status,
+		StatusChanged:  previous.Status != order.Status,
+	}
+}
+
+type MarketsResponse struct {
+	Markets map[string]*Market
 	BaseResponse
 }
 
 type WSRequest struct {
-	Channel Channel                `json:"channel"`
-	Market  string                 `json:"market"`
+	Channel Channel                `json:"channel,omitempty"`
+	Market  string                 `json:"market,omitempty"`
 	Op      Operation              `json:"op"`
-	Args    map[string]interface{} `json:"args"`
+	Args    map[string]interface{} `json:"args,omitempty"`
+	// Grouping is the price grouping of the grouped orderbook channel.
+	Grouping json.Number `json:"grouping,omitempty"`
+	// SubAccount overrides the subaccount the connection of a private channel logs in with.
+	SubAccount string `json:"-"`
 }
 
 func (wr WSRequest) IsPrivateChannel() bool {
@@ -64,6 +134,16 @@ type WsResponse struct {
 	Code    int             `json:"code"`
 	Message string          `json:"msg"`
 	Data    json.RawMessage `json:"data"`
+	// Grouping is set by the grouped orderbook channel.
+	Grouping decimal.Decimal `json:"grouping"`
+}
+
+func (wr *WsResponse) base() BaseResponse {
+	return BaseResponse{
+		Type:   wr.Type,
+		Kind:   EventKindOf(wr.Channel, wr.Type),
+		Symbol: wr.Market,
+	}
 }
 
 func (wr *WsResponse) MapToTradesResponse() (*TradesResponse, error) {
@@ -74,11 +154,8 @@ func (wr *WsResponse) MapToTradesResponse() (*TradesResponse, error) {
 	}
 
 	return &TradesResponse{
-		Trades: trades,
-		BaseResponse: BaseResponse{
-			Type:   wr.Type,
-			Symbol: wr.Market,
-		},
+		Trades:       trades,
+		BaseResponse: wr.base(),
 	}, nil
 }
 
@@ -90,11 +167,8 @@ func (wr *WsResponse) MapToTickerResponse() (*TickerResponse, error) {
 	}
 
 	return &TickerResponse{
-		Ticker: ticker,
-		BaseResponse: BaseResponse{
-			Type:   wr.Type,
-			Symbol: wr.Market,
-		},
+		Ticker:       ticker,
+		BaseResponse: wr.base(),
 	}, nil
 }
 
@@ -106,11 +180,32 @@ func (wr *WsResponse) MapToOrderBookResponse() (*OrderBookResponse, error) {
 	}
 
 	return &OrderBookResponse{
-		OrderBook: book,
-		BaseResponse: BaseResponse{
-			Type:   wr.Type,
-			Symbol: wr.Market,
-		},
+		OrderBook:    book,
+		IsSnapshot:   wr.Type == Partial,
+		BaseResponse: wr.base(),
+	}, nil
+}
+
+func (wr *WsResponse) MapToGroupedOrderBookResponse() (*GroupedOrderBookResponse, error) {
+	var book struct {
+		OrderBook
+		Grouping decimal.Decimal `json:"grouping"`
+	}
+	err := json.Unmarshal(wr.Data, &book)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	grouping := wr.Grouping
+	if grouping.IsZero() {
+		grouping = book.Grouping
+	}
+
+	return &GroupedOrderBookResponse{
+		OrderBook:    book.OrderBook,
+		Grouping:     grouping,
+		IsSnapshot:   wr.Type == Partial,
+		BaseResponse: wr.base(),
 	}, nil
 }
 
@@ -122,11 +217,8 @@ func (wr *WsResponse) MapToFillResponse() (*FillResponse, error) {
 	}
 
 	return &FillResponse{
-		Fill: fill,
-		BaseResponse: BaseResponse{
-			Type:   wr.Type,
-			Symbol: wr.Market,
-		},
+		Fill:         fill,
+		BaseResponse: wr.base(),
 	}, nil
 }
 
@@ -138,10 +230,22 @@ func (wr *WsResponse) MapToOrderResponse() (*OrderResponse, error) {
 	}
 
 	return &OrderResponse{
-		Order: order,
-		BaseResponse: BaseResponse{
-			Type:   wr.Type,
-			Symbol: wr.Market,
-		},
+		Order:        order,
+		BaseResponse: wr.base(),
+	}, nil
+}
+
+func (wr *WsResponse) MapToMarketsResponse() (*MarketsResponse, error) {
+	var markets struct {
+		Data map[string]*Market `json:"data"`
+	}
+	err := json.Unmarshal(wr.Data, &markets)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &MarketsResponse{
+		Markets:      markets.Data,
+		BaseResponse: wr.base(),
 	}, nil
 }
@@ -0,0 +1,520 @@
+package goftx
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	orderReconcileInterval = time.Minute
+	orderClosedRetention   = time.Minute * 10
+	orderChangeBuffer      = 64
+)
+
+type OrderSource string
+
+const (
+	OrderFromStream = OrderSource("stream")
+	OrderFromFill   = OrderSource("fill")
+	OrderFromREST   = OrderSource("rest")
+)
+
+// OrderChange is sent to change subscribers whenever the state of an order changes.
+// Previous is nil for orders seen first.
+type OrderChange struct {
+	Order    models.Order
+	Previous *models.Order
+	Delta    models.OrderDelta
+	Source   OrderSource
+}
+
+type OrderManagerOption func(m *OrderManager)
+
+// WithOrderReconcileInterval sets how often the state is reconciled with REST besides after reconnects, zero disables it.
+func WithOrderReconcileInterval(interval time.Duration) OrderManagerOption {
+	return func(m *OrderManager) {
+		m.reconcileInterval = interval
+	}
+}
+
+// WithClosedOrderRetention sets how long closed orders, and fills of orders that are not known, are kept.
+// They are pruned every retention period, a non-positive retention keeps the default.
+func WithClosedOrderRetention(retention time.Duration) OrderManagerOption {
+	return func(m *OrderManager) {
+		if retention > 0 {
+			m.closedRetention = retention
+		}
+	}
+}
+
+// OrderManager owns the local state of the orders of an account. It is seeded with REST,
+// follows the orders and fills channels and reconciles with REST after every reconnect,
+// so fills and cancels missed while the socket was down are caught.
+type OrderManager struct {
+	client            *Client
+	reconcileInterval time.Duration
+	closedRetention   time.Duration
+
+	mu         sync.RWMutex
+	orders     map[int64]*managedOrder
+	byClientID map[models.ClientID]int64
+	fills      map[int64]*orderFills
+	triggers   map[int64]*models.TriggerOrder
+	watchers   map[int]chan *OrderChange
+	nextID     int
+
+	reconcileC chan struct{}
+}
+
+type managedOrder struct {
+	order    models.Order
+	closedAt time.Time
+}
+
+// orderFills accounts fills of an order, which could arrive before or after the order update that includes them.
+type orderFills struct {
+	seen      map[int64]bool
+	filled    decimal.Decimal
+	notional  decimal.Decimal
+	updatedAt time.Time
+}
+
+func (f *orderFills) avgPrice() decimal.Decimal {
+	if f.filled.IsZero() {
+		return decimal.Zero
+	}
+	return f.notional.Div(f.filled)
+}
+
+func NewOrderManager(client *Client, opts ...OrderManagerOption) *OrderManager {
+	m := &OrderManager{
+		client:            client,
+		reconcileInterval: orderReconcileInterval,
+		closedRetention:   orderClosedRetention,
+		orders:            make(map[int64]*managedOrder),
+		byClientID:        make(map[models.ClientID]int64),
+		fills:             make(map[int64]*orderFills),
+		triggers:          make(map[int64]*models.TriggerOrder),
+		watchers:          make(map[int]chan *OrderChange),
+		reconcileC:        make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Start subscribes to the orders and fills channels, seeds the state with open orders and
+// open trigger orders and keeps it up to date until ctx is done.
+func (m *OrderManager) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	ordersC, err := m.client.Stream.SubscribeToOrders(ctx)
+	if err != nil {
+		cancel()
+		return errors.WithStack(err)
+	}
+	fillsC, err := m.client.Stream.SubscribeToFills(ctx)
+	if err != nil {
+		cancel()
+		return errors.WithStack(err)
+	}
+
+	err = m.Reconcile()
+	if err != nil {
+		cancel()
+		return errors.WithStack(err)
+	}
+
+	removeHandler := m.client.Stream.AddReconnectHandler(func(channel models.Channel) {
+		if channel == models.OrdersChannel || channel == models.FillsChannel {
+			m.requestReconcile()
+		}
+	})
+
+	go func() {
+		defer cancel()
+		defer removeHandler()
+		m.run(ctx, ordersC, fillsC)
+	}()
+
+	return nil
+}
+
+func (m *OrderManager) run(ctx context.Context, ordersC chan *models.OrderResponse, fillsC chan *models.FillResponse) {
+	var reconcileTickerC <-chan time.Time
+	if m.reconcileInterval > 0 {
+		reconcileTicker := time.NewTicker(m.reconcileInterval)
+		defer reconcileTicker.Stop()
+		reconcileTickerC = reconcileTicker.C
+	}
+	pruneTicker := time.NewTicker(m.closedRetention)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case order, ok := <-ordersC:
+			if !ok {
+				ordersC = nil
+				continue
+			}
+			m.applyOrder(order.Order, OrderFromStream)
+		case fill, ok := <-fillsC:
+			if !ok {
+				fillsC = nil
+				continue
+			}
+			m.applyFill(fill.Fill)
+		case <-m.reconcileC:
+			m.reconcile()
+		case <-reconcileTickerC:
+			m.reconcile()
+		case now := <-pruneTicker.C:
+			m.mu.Lock()
+			m.prune(now)
+			m.mu.Unlock()
+		}
+	}
+}
+
+func (m *OrderManager) requestReconcile() {
+	select {
+	case m.reconcileC <- struct{}{}:
+	default:
+	}
+}
+
+func (m *OrderManager) reconcile() {
+	if err := m.Reconcile(); err != nil {
+		m.client.Stream.printf("reconcile orders: %+v", err)
+		m.requestReconcileLater()
+	}
+}
+
+func (m *OrderManager) requestReconcileLater() {
+	time.AfterFunc(reconnectInterval, m.requestReconcile)
+}
+
+// Reconcile brings the state in line with REST: open orders are taken from GetOpenOrders,
+// orders known as open but missing there are fetched one by one to learn how they were closed.
+func (m *OrderManager) Reconcile() error {
+	open, err := m.client.Orders.GetOpenOrders("")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	triggers, err := m.client.Orders.GetOpenTriggerOrders(nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	openIDs := make(map[int64]bool, len(open))
+	for _, order := range open {
+		openIDs[order.ID] = true
+		m.applyOrder(*order, OrderFromREST)
+	}
+
+	var missing []int64
+	m.mu.RLock()
+	for id, managed := range m.orders {
+		if managed.order.Status != models.Closed && !openIDs[id] {
+			missing = append(missing, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range missing {
+		order, err := m.client.Orders.GetOrder(id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		m.applyOrder(*order, OrderFromREST)
+	}
+
+	m.mu.Lock()
+	m.triggers = make(map[int64]*models.TriggerOrder, len(triggers))
+	for _, trigger := range triggers {
+		m.triggers[trigger.ID] = trigger
+	}
+	m.prune(time.Now())
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *OrderManager) applyOrder(order models.Order, source OrderSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var previous *models.Order
+	managed, ok := m.orders[order.ID]
+	if ok {
+		last := managed.order
+		previous = &last
+		if last.Status == models.Closed {
+			// closed is final, late updates of the order are outdated
+			order.Status = models.Closed
+		}
+		if last.FilledSize.GreaterThan(order.FilledSize) {
+			order.FilledSize = last.FilledSize
+			order.AvgFillPrice = last.AvgFillPrice
+		}
+	} else {
+		managed = &managedOrder{}
+		m.orders[order.ID] = managed
+	}
+	if fills, ok := m.fills[order.ID]; ok && fills.filled.GreaterThan(order.FilledSize) {
+		order.FilledSize = fills.filled
+		order.AvgFillPrice = fills.avgPrice()
+	}
+	if order.Status != models.Closed {
+		order.RemainingSize = order.Size.Sub(order.FilledSize)
+	}
+
+	m.update(managed, previous, order, source)
+}
+
+func (m *OrderManager) applyFill(fill models.Fill) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fills, ok := m.fills[fill.OrderID]
+	if !ok {
+		fills = &orderFills{seen: make(map[int64]bool)}
+		m.fills[fill.OrderID] = fills
+	}
+	if fills.seen[fill.ID] {
+		return
+	}
+	fills.seen[fill.ID] = true
+	fills.filled = fills.filled.Add(fill.Size)
+	fills.notional = fills.notional.Add(fill.Price.Mul(fill.Size))
+	fills.updatedAt = time.Now()
+
+	managed, ok := m.orders[fill.OrderID]
+	if !ok || !fills.filled.GreaterThan(managed.order.FilledSize) {
+		return
+	}
+
+	// the fills are ahead of the order, both the size and the price come from them
+	previous := managed.order
+	order := managed.order
+	order.AvgFillPrice = fills.avgPrice()
+	order.FilledSize = fills.filled
+	order.RemainingSize = order.Size.Sub(order.FilledSize)
+	if !order.RemainingSize.IsPositive() {
+		order.RemainingSize = decimal.Zero
+		order.Status = models.Closed
+	}
+
+	m.update(managed, &previous, order, OrderFromFill)
+}
+
+// update stores the new state of an order and notifies subscribers if it changed.
+func (m *OrderManager) update(managed *managedOrder, previous *models.Order, order models.Order, source OrderSource) {
+	managed.order = order
+	if order.ClientID != "" {
+		m.byClientID[order.ClientID] = order.ID
+	}
+	if order.Status == models.Closed && managed.closedAt.IsZero() {
+		managed.closedAt = time.Now()
+	}
+	if previous != nil && !orderChanged(*previous, order) {
+		return
+	}
+
+	change := &OrderChange{
+		Order:    order,
+		Previous: previous,
+		Delta:    models.NewOrderDelta(previous, order),
+		Source:   source,
+	}
+	for _, changesC := range m.watchers {
+		select {
+		case changesC <- change:
+		default:
+		}
+	}
+}
+
+func orderChanged(previous, order models.Order) bool {
+	return previous.Status != order.Status ||
+		!previous.FilledSize.Equal(order.FilledSize) ||
+		!previous.RemainingSize.Equal(order.RemainingSize) ||
+		!previous.Size.Equal(order.Size) ||
+		!previous.Price.Equal(order.Price) ||
+		previous.ClientID != order.ClientID
+}
+
+// prune forgets orders closed for the retention period and fills of unknown orders last seen before it.
+// Callers hold m.mu.
+func (m *OrderManager) prune(now time.Time) {
+	for id, fills := range m.fills {
+		if _, ok := m.orders[id]; !ok && now.Sub(fills.updatedAt) >= m.closedRetention {
+			delete(m.fills, id)
+		}
+	}
+	for id, managed := range m.orders {
+		if managed.closedAt.IsZero() || now.Sub(managed.closedAt) < m.closedRetention {
+			continue
+		}
+		delete(m.orders, id)
+		delete(m.fills, id)
+		if managed.order.ClientID != "" && m.byClientID[managed.order.ClientID] == id {
+			delete(m.byClientID, managed.order.ClientID)
+		}
+	}
+}
+
+func (m *OrderManager) Get(orderID int64) (*models.Order, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	managed, ok := m.orders[orderID]
+	if !ok {
+		return nil, false
+	}
+	order := managed.order
+	return &order, true
+}
+
+func (m *OrderManager) GetByClientID(clientID models.ClientID) (*models.Order, bool) {
+	m.mu.RLock()
+	id, ok := m.byClientID[clientID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return m.Get(id)
+}
+
+// FillAttribution links a fill to the client ID of its order.
+type FillAttribution struct {
+	OrderID  int64
+	ClientID models.ClientID
+	ClientIDInfo
+}
+
+// AttributeFill returns the client ID of the order of fill and the tag decoded from it.
+// Orders unknown to the manager are fetched with REST and kept. Fills without an order
+// or of orders without a client ID have an empty ClientID.
+func (m *OrderManager) AttributeFill(fill *models.Fill) (*FillAttribution, error) {
+	attribution := &FillAttribution{OrderID: fill.OrderID}
+	if fill.OrderID == 0 {
+		return attribution, nil
+	}
+
+	order, ok := m.Get(fill.OrderID)
+	if !ok {
+		var err error
+		order, err = m.client.Orders.GetOrder(fill.OrderID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		m.applyOrder(*order, OrderFromREST)
+	}
+	if order.ClientID != "" {
+		attribution.ClientID = order.ClientID
+		attribution.ClientIDInfo = DecodeClientID(order.ClientID)
+	}
+	return attribution, nil
+}
+
+// OpenOrders returns the open orders sorted by creation time.
+func (m *OrderManager) OpenOrders() []*models.Order {
+	return m.openOrders(func(order *models.Order) bool {
+		return true
+	})
+}
+
+func (m *OrderManager) OpenOrdersByMarket(market string) []*models.Order {
+	return m.openOrders(func(order *models.Order) bool {
+		return order.Market == market
+	})
+}
+
+func (m *OrderManager) OpenOrdersBySide(side models.Side) []*models.Order {
+	return m.openOrders(func(order *models.Order) bool {
+		return order.Side == side
+	})
+}
+
+// OpenOrdersByClientTag returns the open orders whose client ID is tag or starts with tag and ClientTagSeparator.
+func (m *OrderManager) OpenOrdersByClientTag(tag string) []*models.Order {
+	return m.openOrders(func(order *models.Order) bool {
+		return string(order.ClientID) == tag || strings.HasPrefix(string(order.ClientID), tag+ClientTagSeparator)
+	})
+}
+
+func (m *OrderManager) openOrders(filter func(order *models.Order) bool) []*models.Order {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*models.Order
+	for _, managed := range m.orders {
+		if managed.order.Status == models.Closed {
+			continue
+		}
+		order := managed.order
+		if filter(&order) {
+			result = append(result, &order)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// OpenTriggerOrders returns the open trigger orders of market as of the last reconciliation, all if market is empty.
+func (m *OrderManager) OpenTriggerOrders(market string) []*models.TriggerOrder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*models.TriggerOrder
+	for _, trigger := range m.triggers {
+		if market == "" || trigger.Market == market {
+			t := *trigger
+			result = append(result, &t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// SubscribeToChanges notifies about every change of an order.
+// Changes are dropped while the channel is full, it is closed when ctx is done.
+func (m *OrderManager) SubscribeToChanges(ctx context.Context) chan *OrderChange {
+	changesC := make(chan *OrderChange, orderChangeBuffer)
+
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.watchers[id] = changesC
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		delete(m.watchers, id)
+		close(changesC)
+	}()
+
+	return changesC
+}
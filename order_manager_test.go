@@ -0,0 +1,250 @@
+package goftx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestOrderManager(t *testing.T) {
+	btc := models.Order{
+		ID: 1, Market: "BTC-PERP", Side: models.Buy, Status: models.Open, ClientID: "mm-1",
+		Price: decimal.NewFromInt(9000), Size: decimal.NewFromInt(2), RemainingSize: decimal.NewFromInt(2),
+	}
+	eth := models.Order{
+		ID: 2, Market: "ETH-PERP", Side: models.Sell, Status: models.Open, ClientID: "other",
+		Price: decimal.NewFromInt(300), Size: decimal.NewFromInt(5), RemainingSize: decimal.NewFromInt(5),
+	}
+
+	// once reconciled is set the REST state tells that ETH-PERP was cancelled while disconnected
+	var reconciled int32
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /orders": func(r *http.Request) (interface{}, error) {
+			if atomic.LoadInt32(&reconciled) == 1 {
+				return []models.Order{btc}, nil
+			}
+			return []models.Order{btc, eth}, nil
+		},
+		"GET /orders/2": func(r *http.Request) (interface{}, error) {
+			cancelled := eth
+			cancelled.Status = models.Closed
+			cancelled.RemainingSize = decimal.Zero
+			return cancelled, nil
+		},
+		"GET /conditional_orders": func(r *http.Request) (interface{}, error) {
+			return []models.TriggerOrder{{ID: 10, Market: "BTC-PERP"}}, nil
+		},
+	})
+	defer api.Close()
+
+	// every channel has its own connection, it is handed over once the subscription is acknowledged
+	connsC := make(chan *websocket.Conn, 8)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe && req.Channel == models.FillsChannel {
+			connsC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewOrderManager(ftx, WithOrderReconcileInterval(0))
+	require.NoError(t, manager.Start(ctx))
+
+	require.Len(t, manager.OpenOrders(), 2)
+	tagged := manager.OpenOrdersByClientTag("mm")
+	require.Len(t, tagged, 1)
+	require.EqualValues(t, 1, tagged[0].ID)
+	require.Len(t, manager.OpenOrdersBySide(models.Sell), 1)
+	require.Len(t, manager.OpenTriggerOrders("BTC-PERP"), 1)
+	require.Empty(t, manager.OpenTriggerOrders("ETH-PERP"))
+
+	changes := manager.SubscribeToChanges(ctx)
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-connsC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fills subscription")
+	}
+	fill := map[string]interface{}{
+		"channel": models.FillsChannel,
+		"type":    models.Update,
+		"data":    map[string]interface{}{"id": 100, "orderId": 1, "market": "BTC-PERP", "price": 9000, "size": 1},
+	}
+	require.NoError(t, conn.WriteJSON(fill))
+	// a duplicated fill is not counted twice
+	require.NoError(t, conn.WriteJSON(fill))
+
+	select {
+	case change := <-changes:
+		require.Equal(t, OrderFromFill, change.Source)
+		require.EqualValues(t, 1, change.Order.ID)
+		require.True(t, change.Order.FilledSize.Equal(decimal.NewFromInt(1)))
+		require.True(t, change.Delta.FilledSize.Equal(decimal.NewFromInt(1)))
+		require.True(t, change.Order.AvgFillPrice.Equal(decimal.NewFromInt(9000)))
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change received")
+	}
+
+	atomic.StoreInt32(&reconciled, 1)
+	ts.dropConnections()
+
+	select {
+	case change := <-changes:
+		require.Equal(t, OrderFromREST, change.Source)
+		require.EqualValues(t, 2, change.Order.ID)
+		require.Equal(t, models.Closed, change.Order.Status)
+		require.True(t, change.Delta.StatusChanged)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconciliation after reconnect")
+	}
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected change %+v", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	order, ok := manager.GetByClientID("mm-1")
+	require.True(t, ok)
+	require.True(t, order.FilledSize.Equal(decimal.NewFromInt(1)))
+	require.True(t, order.RemainingSize.Equal(decimal.NewFromInt(1)))
+	require.Len(t, manager.OpenOrdersByMarket("ETH-PERP"), 0)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, ok := <-changes
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOrderManager_AttributeFill(t *testing.T) {
+	quote := NewClientIDSequence().Next("mm")
+	var fetched int32
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /orders": func(r *http.Request) (interface{}, error) {
+			return []models.Order{{ID: 1, Market: "BTC-PERP", Status: models.Open, ClientID: quote}}, nil
+		},
+		"GET /conditional_orders": func(r *http.Request) (interface{}, error) {
+			return []models.TriggerOrder{}, nil
+		},
+		"GET /orders/7": func(r *http.Request) (interface{}, error) {
+			atomic.AddInt32(&fetched, 1)
+			return models.Order{ID: 7, Market: "BTC-PERP", Status: models.Closed, ClientID: "twap-3"}, nil
+		},
+	})
+	defer api.Close()
+
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewOrderManager(ftx, WithOrderReconcileInterval(0))
+	require.NoError(t, manager.Start(ctx))
+
+	attribution, err := manager.AttributeFill(&models.Fill{ID: 100, OrderID: 1})
+	require.NoError(t, err)
+	require.Equal(t, quote, attribution.ClientID)
+	require.Equal(t, "mm", attribution.Tag)
+	require.EqualValues(t, 1, attribution.Sequence)
+
+	// orders closed before the manager started are fetched once
+	for i := 0; i < 2; i++ {
+		attribution, err = manager.AttributeFill(&models.Fill{ID: 101, OrderID: 7})
+		require.NoError(t, err)
+		require.Equal(t, "twap", attribution.Tag)
+		require.EqualValues(t, 3, attribution.Sequence)
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&fetched))
+
+	attribution, err = manager.AttributeFill(&models.Fill{ID: 102})
+	require.NoError(t, err)
+	require.Empty(t, attribution.ClientID)
+	require.Empty(t, attribution.Tag)
+}
+
+func TestOrderManager_FillsBeforeOrder(t *testing.T) {
+	manager := NewOrderManager(New())
+
+	manager.applyFill(models.Fill{ID: 100, OrderID: 1, Price: decimal.NewFromInt(100), Size: decimal.NewFromInt(1)})
+	// the update of the order is behind its fills
+	manager.applyOrder(models.Order{ID: 1, Status: models.Open, Size: decimal.NewFromInt(3)}, OrderFromStream)
+	manager.applyFill(models.Fill{ID: 101, OrderID: 1, Price: decimal.NewFromInt(200), Size: decimal.NewFromInt(1)})
+
+	order, ok := manager.Get(1)
+	require.True(t, ok)
+	require.Equal(t, "2", order.FilledSize.String())
+	require.Equal(t, "150", order.AvgFillPrice.String())
+	require.Equal(t, "1", order.RemainingSize.String())
+}
+
+func TestOrderManager_Prune(t *testing.T) {
+	manager := NewOrderManager(New(), WithClosedOrderRetention(time.Minute))
+
+	manager.applyOrder(models.Order{ID: 1, Status: models.Closed, Size: decimal.NewFromInt(1), ClientID: "a"}, OrderFromStream)
+	manager.applyOrder(models.Order{ID: 2, Status: models.Open, Size: decimal.NewFromInt(1)}, OrderFromStream)
+	// a fill of an order that is never seen
+	manager.applyFill(models.Fill{ID: 100, OrderID: 3, Price: decimal.NewFromInt(100), Size: decimal.NewFromInt(1)})
+
+	manager.mu.Lock()
+	manager.prune(time.Now())
+	require.Len(t, manager.orders, 2)
+	require.Len(t, manager.fills, 1)
+	manager.prune(time.Now().Add(time.Minute))
+	require.Len(t, manager.orders, 1)
+	require.Empty(t, manager.fills)
+	require.Empty(t, manager.byClientID)
+	manager.mu.Unlock()
+
+	_, ok := manager.Get(2)
+	require.True(t, ok)
+}
+
+func TestOrderManager_StartFailed(t *testing.T) {
+	api := newTestAPI(t, map[string]testRoute{})
+	defer api.Close()
+
+	ts := newTestServer(t, nil)
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewOrderManager(ftx, WithOrderReconcileInterval(0))
+	require.Error(t, manager.Start(ctx))
+
+	// the subscriptions opened before the failed reconciliation are closed
+	unsubscribed := make(map[models.Channel]bool)
+	for len(unsubscribed) < 2 {
+		select {
+		case req := <-ts.requestsC:
+			if req.Op == models.UnSubscribe {
+				unsubscribed[req.Channel] = true
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("subscriptions are not closed, unsubscribed %v", unsubscribed)
+		}
+	}
+	require.True(t, unsubscribed[models.OrdersChannel])
+	require.True(t, unsubscribed[models.FillsChannel])
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/pkg/errors"
 
@@ -14,11 +15,14 @@ const (
 	apiOrders                  = "/orders"
 	apiGetOrdersHistory        = "/orders/history"
 	apiModifyOrder             = "/orders/%d/modify"
-	apiModifyOrderByClientID   = "/orders/by_client_id/%d/modify"
+	apiModifyOrderByClientID   = "/orders/by_client_id/%s/modify"
 	apiTriggerOrders           = "/conditional_orders"
 	apiGetOrderTriggers        = "/conditional_orders/%d/triggers"
 	apiGetTriggerOrdersHistory = "/conditional_orders/history"
 	apiModifyTriggerOrder      = "/conditional_orders/%d/modify"
+	apiTwapOrders              = "/twap_orders"
+	apiGetTwapOrdersHistory    = "/twap_orders/history"
+	apiGetTwapOrderExecutions  = "/twap_orders/%d/executions"
 )
 
 type Orders struct {
@@ -171,6 +175,13 @@ func (o *Orders) GetTriggerOrdersHistory(params *models.GetTriggerOrdersHistoryP
 }
 
 func (o *Orders) PlaceOrder(payload *models.PlaceOrderPayload) (*models.Order, error) {
+	if o.client.validator != nil {
+		err := o.client.validator.ValidatePlaceOrder(payload)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -196,6 +207,9 @@ func (o *Orders) PlaceOrder(payload *models.PlaceOrderPayload) (*models.Order, e
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if o.client.validator != nil {
+		o.client.validator.rememberOrder(result)
+	}
 
 	return result, nil
 }
@@ -205,6 +219,12 @@ func (o *Orders) PlaceTriggerOrder(payload *models.PlaceTriggerOrderPayload) (*m
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if o.client.validator != nil {
+		err = o.client.validator.ValidatePlaceTriggerOrder(payload)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -236,6 +256,13 @@ func (o *Orders) PlaceTriggerOrder(payload *models.PlaceTriggerOrderPayload) (*m
 }
 
 func (o *Orders) ModifyOrder(payload *models.ModifyOrderPayload, orderID int64) (*models.Order, error) {
+	if o.client.validator != nil {
+		err := o.client.validator.ValidateModifyOrder(payload, orderID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -261,11 +288,14 @@ func (o *Orders) ModifyOrder(payload *models.ModifyOrderPayload, orderID int64)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if o.client.validator != nil {
+		o.client.validator.rememberOrder(result)
+	}
 
 	return result, nil
 }
 
-func (o *Orders) ModifyOrderByClientID(payload *models.ModifyOrderPayload, clientOrderID int64) (*models.Order, error) {
+func (o *Orders) ModifyOrderByClientID(payload *models.ModifyOrderPayload, clientOrderID models.ClientID) (*models.Order, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -274,7 +304,7 @@ func (o *Orders) ModifyOrderByClientID(payload *models.ModifyOrderPayload, clien
 	request, err := o.client.prepareRequest(Request{
 		Auth:   true,
 		Method: http.MethodPost,
-		URL:    fmt.Sprintf("%s%s", o.client.apiURL, fmt.Sprintf(apiModifyOrderByClientID, clientOrderID)),
+		URL:    fmt.Sprintf("%s%s", o.client.apiURL, fmt.Sprintf(apiModifyOrderByClientID, url.PathEscape(string(clientOrderID)))),
 		Body:   body,
 	})
 	if err != nil {
@@ -349,11 +379,11 @@ func (o *Orders) GetOrder(orderID int64) (*models.Order, error) {
 	return result, nil
 }
 
-func (o *Orders) GetOrderByClientID(clientOrderID string) (*models.Order, error) {
+func (o *Orders) GetOrderByClientID(clientOrderID models.ClientID) (*models.Order, error) {
 	request, err := o.client.prepareRequest(Request{
 		Auth:   true,
 		Method: http.MethodGet,
-		URL:    fmt.Sprintf("%s%s/by_client_id/%s", o.client.apiURL, apiOrders, clientOrderID),
+		URL:    fmt.Sprintf("%s%s/by_client_id/%s", o.client.apiURL, apiOrders, url.PathEscape(string(clientOrderID))),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -391,11 +421,11 @@ func (o *Orders) CancelOrder(orderID int64) error {
 	return nil
 }
 
-func (o *Orders) CancelOrderByClientID(clientOrderID string) error {
+func (o *Orders) CancelOrderByClientID(clientOrderID models.ClientID) error {
 	request, err := o.client.prepareRequest(Request{
 		Auth:   true,
 		Method: http.MethodDelete,
-		URL:    fmt.Sprintf("%s%s/by_client_id/%s", o.client.apiURL, apiOrders, clientOrderID),
+		URL:    fmt.Sprintf("%s%s/by_client_id/%s", o.client.apiURL, apiOrders, url.PathEscape(string(clientOrderID))),
 	})
 	if err != nil {
 		return errors.WithStack(err)
@@ -450,3 +480,143 @@ func (o *Orders) CancelAllOrders(payload *models.CancelAllOrdersPayload) error {
 
 	return nil
 }
+
+// GetTwapOrders returns running TWAP orders.
+func (o *Orders) GetTwapOrders(params *models.GetTwapOrdersParams) ([]*models.TwapOrder, error) {
+	queryParams, err := PrepareQueryParams(params)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	request, err := o.client.prepareRequest(Request{
+		Auth:   true,
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("%s%s", o.client.apiURL, apiTwapOrders),
+		Params: queryParams,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	response, err := o.client.do(request)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result []*models.TwapOrder
+	err = json.Unmarshal(response, &result)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+// GetTwapOrdersHistory returns completed and cancelled TWAP orders.
+func (o *Orders) GetTwapOrdersHistory(params *models.GetTwapOrdersHistoryParams) ([]*models.TwapOrder, error) {
+	queryParams, err := PrepareQueryParams(params)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	request, err := o.client.prepareRequest(Request{
+		Auth:   true,
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("%s%s", o.client.apiURL, apiGetTwapOrdersHistory),
+		Params: queryParams,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	response, err := o.client.do(request)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result []*models.TwapOrder
+	err = json.Unmarshal(response, &result)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+// GetTwapOrderExecutions returns the fills of the child orders of a TWAP order.
+func (o *Orders) GetTwapOrderExecutions(twapOrderID int64) ([]*models.Fill, error) {
+	request, err := o.client.prepareRequest(Request{
+		Auth:   true,
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("%s%s", o.client.apiURL, fmt.Sprintf(apiGetTwapOrderExecutions, twapOrderID)),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	response, err := o.client.do(request)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result []*models.Fill
+	err = json.Unmarshal(response, &result)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+func (o *Orders) PlaceTwapOrder(payload *models.PlaceTwapOrderPayload) (*models.TwapOrder, error) {
+	err := payload.Validate()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	request, err := o.client.prepareRequest(Request{
+		Auth:   true,
+		Method: http.MethodPost,
+		URL:    fmt.Sprintf("%s%s", o.client.apiURL, apiTwapOrders),
+		Body:   body,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	response, err := o.client.do(request)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result *models.TwapOrder
+	err = json.Unmarshal(response, &result)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+func (o *Orders) CancelTwapOrder(twapOrderID int64) error {
+	request, err := o.client.prepareRequest(Request{
+		Auth:   true,
+		Method: http.MethodDelete,
+		URL:    fmt.Sprintf("%s%s/%d", o.client.apiURL, apiTwapOrders, twapOrderID),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = o.client.do(request)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
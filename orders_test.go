@@ -1,10 +1,14 @@
 package goftx
 
 import (
+	"encoding/json"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -108,3 +112,150 @@ func TestOrders_GetTriggerOrdersHistory(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, triggers)
 }
+
+func TestOrders_TwapOrders(t *testing.T) {
+	var placed models.PlaceTwapOrderPayload
+	cancelled := false
+	twap := models.TwapOrder{
+		ID:              1,
+		Market:          "BTC-PERP",
+		Side:            models.Buy,
+		Size:            decimal.NewFromInt(2),
+		Status:          models.TwapRunning,
+		DurationSeconds: 3600,
+		RandomizeSize:   true,
+	}
+	api := newTestAPI(t, map[string]testRoute{
+		"POST /twap_orders": func(r *http.Request) (interface{}, error) {
+			err := json.NewDecoder(r.Body).Decode(&placed)
+			return twap, err
+		},
+		"GET /twap_orders": func(r *http.Request) (interface{}, error) {
+			require.Equal(t, "BTC-PERP", r.URL.Query().Get("market"))
+			return []models.TwapOrder{twap}, nil
+		},
+		"GET /twap_orders/history": func(r *http.Request) (interface{}, error) {
+			require.Equal(t, "10", r.URL.Query().Get("limit"))
+			completed := twap
+			completed.Status = models.TwapCompleted
+			completed.FilledSize = completed.Size
+			return []models.TwapOrder{completed}, nil
+		},
+		"GET /twap_orders/1/executions": func(r *http.Request) (interface{}, error) {
+			return []models.Fill{{ID: 100, Market: "BTC-PERP", Size: decimal.NewFromInt(1)}}, nil
+		},
+		"DELETE /twap_orders/1": func(r *http.Request) (interface{}, error) {
+			cancelled = true
+			return nil, nil
+		},
+	})
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	payload := &models.PlaceTwapOrderPayload{
+		Market:          "BTC-PERP",
+		Side:            models.Buy,
+		Size:            decimal.NewFromInt(2),
+		Type:            models.LimitOrder,
+		DurationSeconds: 3600,
+	}
+	_, err := ftx.Orders.PlaceTwapOrder(payload)
+	require.Error(t, err)
+
+	randomize := true
+	payload.Type = models.MarketOrder
+	payload.RandomizeSize = &randomize
+	order, err := ftx.Orders.PlaceTwapOrder(payload)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, order.ID)
+	require.Equal(t, time.Hour, order.Duration())
+	require.Equal(t, models.MarketOrder, placed.Type)
+	require.True(t, *placed.RandomizeSize)
+	require.EqualValues(t, 3600, placed.DurationSeconds)
+
+	market := "BTC-PERP"
+	running, err := ftx.Orders.GetTwapOrders(&models.GetTwapOrdersParams{Market: &market})
+	require.NoError(t, err)
+	require.Len(t, running, 1)
+	require.Equal(t, models.TwapRunning, running[0].Status)
+
+	limit := 10
+	history, err := ftx.Orders.GetTwapOrdersHistory(&models.GetTwapOrdersHistoryParams{Limit: &limit})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, models.TwapCompleted, history[0].Status)
+
+	fills, err := ftx.Orders.GetTwapOrderExecutions(1)
+	require.NoError(t, err)
+	require.Len(t, fills, 1)
+	require.EqualValues(t, 100, fills[0].ID)
+
+	require.NoError(t, ftx.Orders.CancelTwapOrder(1))
+	require.True(t, cancelled)
+}
+
+func TestOrders_ByClientID(t *testing.T) {
+	clientID := models.ClientID("mm-0klqj5hc0-000001")
+	var modified models.ModifyOrderPayload
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /orders/by_client_id/mm-0klqj5hc0-000001": func(r *http.Request) (interface{}, error) {
+			return models.Order{ID: 1, ClientID: clientID}, nil
+		},
+		"POST /orders/by_client_id/mm-0klqj5hc0-000001/modify": func(r *http.Request) (interface{}, error) {
+			err := json.NewDecoder(r.Body).Decode(&modified)
+			return models.Order{ID: 2, ClientID: *modified.ClientID}, err
+		},
+		"DELETE /orders/by_client_id/mm-0klqj5hc0-000002": func(r *http.Request) (interface{}, error) {
+			return "Order queued for cancellation", nil
+		},
+	})
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	order, err := ftx.Orders.GetOrderByClientID(clientID)
+	require.NoError(t, err)
+	require.Equal(t, clientID, order.ClientID)
+
+	size := decimal.NewFromInt(2)
+	next := models.ClientID("mm-0klqj5hc0-000002")
+	order, err = ftx.Orders.ModifyOrderByClientID(&models.ModifyOrderPayload{Size: &size, ClientID: &next}, clientID)
+	require.NoError(t, err)
+	require.Equal(t, next, order.ClientID)
+
+	require.NoError(t, ftx.Orders.CancelOrderByClientID(next))
+}
+
+func TestOrders_ByClientIDEscaped(t *testing.T) {
+	clientID := models.ClientID("mm/1?a#b")
+	var paths []string
+	route := func(r *http.Request) (interface{}, error) {
+		paths = append(paths, r.URL.EscapedPath())
+		return models.Order{ID: 1, ClientID: clientID}, nil
+	}
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /orders/by_client_id/mm/1?a#b":         route,
+		"POST /orders/by_client_id/mm/1?a#b/modify": route,
+		"DELETE /orders/by_client_id/mm/1?a#b":      route,
+	})
+	defer api.Close()
+
+	ftx := New(WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	_, err := ftx.Orders.GetOrderByClientID(clientID)
+	require.NoError(t, err)
+	size := decimal.NewFromInt(2)
+	_, err = ftx.Orders.ModifyOrderByClientID(&models.ModifyOrderPayload{Size: &size}, clientID)
+	require.NoError(t, err)
+	require.NoError(t, ftx.Orders.CancelOrderByClientID(clientID))
+
+	require.Equal(t, []string{
+		"/orders/by_client_id/mm%2F1%3Fa%23b",
+		"/orders/by_client_id/mm%2F1%3Fa%23b/modify",
+		"/orders/by_client_id/mm%2F1%3Fa%23b",
+	}, paths)
+}
@@ -0,0 +1,212 @@
+package goftx
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// ShardPolicy splits subscription requests into groups, every group is served by its own connection.
+type ShardPolicy func(requests []models.WSRequest) [][]models.WSRequest
+
+// ShardByChannel puts every channel on its own connection.
+func ShardByChannel() ShardPolicy {
+	return func(requests []models.WSRequest) [][]models.WSRequest {
+		var (
+			shards  [][]models.WSRequest
+			indexes = make(map[models.Channel]int)
+		)
+		for _, req := range requests {
+			i, ok := indexes[req.Channel]
+			if !ok {
+				i = len(shards)
+				indexes[req.Channel] = i
+				shards = append(shards, nil)
+			}
+			shards[i] = append(shards[i], req)
+		}
+		return shards
+	}
+}
+
+// ShardByMarketHash spreads markets over count connections by the hash of the market name,
+// so a market always lands on the same connection.
+func ShardByMarketHash(count int) ShardPolicy {
+	return func(requests []models.WSRequest) [][]models.WSRequest {
+		if count < 1 {
+			return [][]models.WSRequest{requests}
+		}
+
+		buckets := make([][]models.WSRequest, count)
+		for _, req := range requests {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(req.Market))
+			i := int(h.Sum32() % uint32(count))
+			buckets[i] = append(buckets[i], req)
+		}
+
+		shards := make([][]models.WSRequest, 0, count)
+		for _, bucket := range buckets {
+			if len(bucket) > 0 {
+				shards = append(shards, bucket)
+			}
+		}
+		return shards
+	}
+}
+
+// ShardByMaxSubscriptions limits the number of subscriptions per connection.
+func ShardByMaxSubscriptions(max int) ShardPolicy {
+	return func(requests []models.WSRequest) [][]models.WSRequest {
+		if max < 1 {
+			return [][]models.WSRequest{requests}
+		}
+
+		shards := make([][]models.WSRequest, 0, len(requests)/max+1)
+		for start := 0; start < len(requests); start += max {
+			end := start + max
+			if end > len(requests) {
+				end = len(requests)
+			}
+			shards = append(shards, requests[start:end])
+		}
+		return shards
+	}
+}
+
+// CombineShardPolicies applies policies one after another, each splitting the shards of the previous one.
+func CombineShardPolicies(policies ...ShardPolicy) ShardPolicy {
+	return func(requests []models.WSRequest) [][]models.WSRequest {
+		shards := [][]models.WSRequest{requests}
+		for _, policy := range policies {
+			var next [][]models.WSRequest
+			for _, shard := range shards {
+				next = append(next, policy(shard)...)
+			}
+			shards = next
+		}
+		return shards
+	}
+}
+
+// StreamPool shards subscriptions over several connections of a Stream.
+// Every connection reconnects on its own, the output of all connections is merged.
+type StreamPool struct {
+	stream *Stream
+	policy ShardPolicy
+}
+
+func NewStreamPool(stream *Stream, policy ShardPolicy) *StreamPool {
+	return &StreamPool{
+		stream: stream,
+		policy: policy,
+	}
+}
+
+// Subscribe serves requests of any channels and merges their responses into one channel.
+func (p *StreamPool) Subscribe(ctx context.Context, requests ...models.WSRequest) (chan interface{}, error) {
+	ctx, eventsC, err := p.subscribe(ctx, requests)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardValues(ctx, eventsC), nil
+}
+
+func (p *StreamPool) subscribe(ctx context.Context, requests []models.WSRequest) (context.Context, chan event, error) {
+	if len(requests) == 0 {
+		return nil, nil, errors.New("requests is missing")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	shards := p.policy(requests)
+	shardsC := make([]chan event, 0, len(shards))
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		_, eventsC, err := p.stream.serve(ctx, shard...)
+		if err != nil {
+			cancel()
+			return nil, nil, errors.WithStack(err)
+		}
+		shardsC = append(shardsC, eventsC)
+	}
+
+	return ctx, mergeEvents(ctx, cancel, shardsC), nil
+}
+
+// mergeEvents fans in events of several serve loops. cancel is called once all of them are closed.
+func mergeEvents(ctx context.Context, cancel context.CancelFunc, chans []chan event) chan event {
+	var wg sync.WaitGroup
+	eventsC := make(chan event, len(chans))
+	for _, c := range chans {
+		wg.Add(1)
+		go func(c chan event) {
+			defer wg.Done()
+			for ev := range c {
+				select {
+				case eventsC <- ev:
+				case <-ctx.Done():
+				}
+			}
+		}(c)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(eventsC)
+	}()
+
+	return eventsC
+}
+
+func (p *StreamPool) SubscribeToTickers(ctx context.Context, symbols ...string) (chan *models.TickerResponse, error) {
+	ctx, eventsC, err := p.subscribeToMarkets(ctx, models.TickerChannel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTickers(ctx, eventsC), nil
+}
+
+func (p *StreamPool) SubscribeToTrades(ctx context.Context, symbols ...string) (chan *models.TradeResponse, error) {
+	ctx, eventsC, err := p.subscribeToMarkets(ctx, models.TradesChannel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTrades(ctx, eventsC), nil
+}
+
+func (p *StreamPool) SubscribeToOrderBooks(ctx context.Context, symbols ...string) (chan *models.OrderBookResponse, error) {
+	ctx, eventsC, err := p.subscribeToMarkets(ctx, models.OrderBookChannel, symbols)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardOrderBooks(ctx, eventsC), nil
+}
+
+func (p *StreamPool) subscribeToMarkets(ctx context.Context, channel models.Channel, symbols []string) (context.Context, chan event, error) {
+	if len(symbols) == 0 {
+		return nil, nil, errors.New("symbols is missing")
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: channel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+		})
+	}
+
+	return p.subscribe(ctx, requests)
+}
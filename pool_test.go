@@ -0,0 +1,94 @@
+package goftx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestShardPolicies(t *testing.T) {
+	requests := []models.WSRequest{
+		{Channel: models.TickerChannel, Market: "BTC-PERP"},
+		{Channel: models.TickerChannel, Market: "ETH-PERP"},
+		{Channel: models.TickerChannel, Market: "SOL-PERP"},
+		{Channel: models.TradesChannel, Market: "BTC-PERP"},
+		{Channel: models.TradesChannel, Market: "ETH-PERP"},
+	}
+
+	shards := ShardByChannel()(requests)
+	require.Len(t, shards, 2)
+	require.Len(t, shards[0], 3)
+	require.Len(t, shards[1], 2)
+
+	shards = ShardByMaxSubscriptions(2)(requests)
+	require.Len(t, shards, 3)
+	require.Len(t, shards[2], 1)
+
+	shards = CombineShardPolicies(ShardByChannel(), ShardByMaxSubscriptions(2))(requests)
+	require.Len(t, shards, 3)
+
+	shards = ShardByMarketHash(4)(requests)
+	total := 0
+	shardOf := map[string]int{}
+	for i, shard := range shards {
+		for _, req := range shard {
+			if j, ok := shardOf[req.Market]; ok {
+				require.Equal(t, j, i, "market %v is in several shards", req.Market)
+			}
+			shardOf[req.Market] = i
+		}
+		total += len(shard)
+	}
+	require.Equal(t, len(requests), total)
+}
+
+func TestStreamPool_SubscribeToTickers(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data":    map[string]interface{}{"bid": 1, "ask": 2, "last": 1.5, "time": 1600000000.1},
+		})
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	pool := NewStreamPool(&ftx.Stream, ShardByMaxSubscriptions(2))
+
+	symbols := make([]string, 5)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("COIN%d-PERP", i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	data, err := pool.SubscribeToTickers(ctx, symbols...)
+	require.NoError(t, err)
+
+	received := map[string]bool{}
+	for len(received) < len(symbols) {
+		select {
+		case msg := <-data:
+			received[msg.Symbol] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received only %v", received)
+		}
+	}
+
+	ts.mu.Lock()
+	require.Len(t, ts.conns, 3)
+	ts.mu.Unlock()
+
+	cancel()
+	for range data {
+	}
+}
@@ -0,0 +1,553 @@
+package goftx
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const futureMarkInterval = time.Second * 5
+
+type MarkPriceSource string
+
+const (
+	// MarkFromTicker marks positions to the last price of the ticker channel.
+	MarkFromTicker = MarkPriceSource("ticker")
+	// MarkFromFutures marks futures positions to the mark price of Futures.GetFutures, other markets to the ticker.
+	MarkFromFutures = MarkPriceSource("futures")
+)
+
+// TrackedPosition is the position of a subaccount in a market. NetSize is negative for shorts,
+// RealizedPnl includes fees paid since the tracker started.
+type TrackedPosition struct {
+	SubAccount    string
+	Market        string
+	NetSize       decimal.Decimal
+	EntryPrice    decimal.Decimal
+	MarkPrice     decimal.Decimal
+	RealizedPnl   decimal.Decimal
+	UnrealizedPnl decimal.Decimal
+	Fees          decimal.Decimal
+	UpdatedAt     time.Time
+}
+
+// PnL is a sum of positions.
+type PnL struct {
+	Realized   decimal.Decimal
+	Unrealized decimal.Decimal
+	Fees       decimal.Decimal
+}
+
+func (p PnL) Total() decimal.Decimal {
+	return p.Realized.Add(p.Unrealized)
+}
+
+type PositionTrackerOption func(t *PositionTracker)
+
+// WithPositionSubAccounts tracks the given subaccounts instead of the subaccount of the client.
+// Every subaccount is seeded with requests on its behalf, so the API key must have access to them.
+func WithPositionSubAccounts(subAccounts ...string) PositionTrackerOption {
+	return func(t *PositionTracker) {
+		t.subAccounts = subAccounts
+	}
+}
+
+// WithMarkPrice sets the source of mark prices and how often futures marks are polled.
+// A non-positive interval keeps the default.
+func WithMarkPrice(source MarkPriceSource, interval time.Duration) PositionTrackerOption {
+	return func(t *PositionTracker) {
+		t.markSource = source
+		if interval > 0 {
+			t.markInterval = interval
+		}
+	}
+}
+
+// WithPositionHub sets the hub the tickers are subscribed with, so they could be shared with other consumers.
+// By default the tracker has a hub of its own.
+func WithPositionHub(hub *Hub) PositionTrackerOption {
+	return func(t *PositionTracker) {
+		t.hub = hub
+	}
+}
+
+// PositionTracker keeps positions and balances from the fills channel and marks positions to market
+// from the ticker channel, tickers of every market share the connection of a Hub.
+// Positions are seeded with Account.GetPositions and balances with Wallet.GetBalances. Fills received
+// while a subaccount is seeded are held back, fills at or before its snapshot are dropped.
+type PositionTracker struct {
+	client       *Client
+	subAccounts  []string
+	markSource   MarkPriceSource
+	markInterval time.Duration
+	hub          *Hub
+
+	mu        sync.RWMutex
+	positions map[positionKey]*TrackedPosition
+	balances  map[string]map[string]*models.Balance
+	marks     map[string]decimal.Decimal
+	futures   map[string]bool
+	watched   map[string]bool
+	markC     chan *models.TickerResponse
+	// seededAt is the snapshot time of every seeded subaccount, early has the fills received before it.
+	seededAt map[string]time.Time
+	early    map[string][]models.Fill
+}
+
+type positionKey struct {
+	subAccount string
+	market     string
+}
+
+func NewPositionTracker(client *Client, opts ...PositionTrackerOption) *PositionTracker {
+	t := &PositionTracker{
+		client:       client,
+		markSource:   MarkFromTicker,
+		markInterval: futureMarkInterval,
+		positions:    make(map[positionKey]*TrackedPosition),
+		balances:     make(map[string]map[string]*models.Balance),
+		marks:        make(map[string]decimal.Decimal),
+		futures:      make(map[string]bool),
+		watched:      make(map[string]bool),
+		markC:        make(chan *models.TickerResponse, 1),
+		seededAt:     make(map[string]time.Time),
+		early:        make(map[string][]models.Fill),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.hub == nil {
+		t.hub = NewHub(&client.Stream)
+	}
+	return t
+}
+
+// Start subscribes to fills, seeds positions and balances with REST and tracks them until ctx is done.
+// Tickers are subscribed for every market with a position, markets traded later are subscribed on their first fill.
+func (t *PositionTracker) Start(ctx context.Context) error {
+	fillsC, err := t.client.Stream.SubscribeToFills(ctx, t.subAccounts...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	go t.run(ctx, fillsC)
+
+	subAccounts := t.subAccounts
+	if len(subAccounts) == 0 {
+		subAccounts = []string{t.client.subAccount}
+	}
+	for _, subAccount := range subAccounts {
+		err = t.seed(subAccount)
+		if err != nil {
+			return errors.Wrapf(err, "subaccount %v", subAccount)
+		}
+	}
+
+	if t.markSource == MarkFromFutures {
+		err = t.refreshFutureMarks()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		go t.pollFutureMarks(ctx)
+	}
+
+	t.mu.RLock()
+	markets := make([]string, 0, len(t.positions))
+	for key := range t.positions {
+		markets = append(markets, key.market)
+	}
+	t.mu.RUnlock()
+	for _, market := range markets {
+		err = t.watch(ctx, market)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// seed loads the positions and balances of subAccount and applies the fills received since the snapshot.
+func (t *PositionTracker) seed(subAccount string) error {
+	client := t.subAccountClient(subAccount)
+	balances, err := client.Wallet.GetBalances()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	positions, err := client.Account.GetPositions()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	seededAt := time.Now().UTC().Add(t.client.serverTimeDiff)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, position := range positions {
+		if position.NetSize.IsZero() && position.RealizedPnl.IsZero() {
+			continue
+		}
+		t.positions[positionKey{subAccount: subAccount, market: position.Future}] = &TrackedPosition{
+			SubAccount:  subAccount,
+			Market:      position.Future,
+			NetSize:     position.NetSize,
+			EntryPrice:  position.EntryPrice,
+			RealizedPnl: position.RealizedPnl,
+			UpdatedAt:   time.Now(),
+		}
+	}
+
+	coins := make(map[string]*models.Balance, len(balances))
+	for _, balance := range balances {
+		b := *balance
+		coins[balance.Coin] = &b
+	}
+	t.balances[subAccount] = coins
+
+	t.seededAt[subAccount] = seededAt
+	for _, fill := range t.early[subAccount] {
+		if isAfterSnapshot(fill, seededAt) {
+			t.applyFill(subAccount, fill)
+		}
+	}
+	delete(t.early, subAccount)
+
+	return nil
+}
+
+// subAccountClient returns a copy of the client which makes REST requests on behalf of subAccount.
+func (t *PositionTracker) subAccountClient(subAccount string) *Client {
+	if subAccount == t.client.subAccount {
+		return t.client
+	}
+	c := &Client{
+		client:         t.client.client,
+		apiKey:         t.client.apiKey,
+		secret:         t.client.secret,
+		subAccount:     subAccount,
+		serverTimeDiff: t.client.serverTimeDiff,
+		isFtxUS:        t.client.isFtxUS,
+		apiURL:         t.client.apiURL,
+	}
+	c.Account = Account{client: c}
+	c.Wallet = Wallet{client: c}
+	return c
+}
+
+// isAfterSnapshot reports whether fill is not part of a snapshot taken at seededAt.
+// Fills without a time are taken as new.
+func isAfterSnapshot(fill models.Fill, seededAt time.Time) bool {
+	return fill.Time.Time.IsZero() || fill.Time.Time.After(seededAt)
+}
+
+func (t *PositionTracker) run(ctx context.Context, fillsC chan *models.FillResponse) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fill, ok := <-fillsC:
+			if !ok {
+				fillsC = nil
+				continue
+			}
+			if !t.bookFill(fill.SubAccount, fill.Fill) {
+				continue
+			}
+			if err := t.watch(ctx, fill.Market); err != nil {
+				t.client.Stream.printf("subscribe to ticker %v: %+v", fill.Market, err)
+			}
+		case ticker := <-t.markC:
+			if t.markSource == MarkFromFutures && t.isFuture(ticker.Symbol) {
+				continue
+			}
+			t.mark(ticker.Symbol, ticker.Last)
+		}
+	}
+}
+
+// bookFill applies a fill received after the snapshot of its subaccount and reports whether it was applied.
+// Fills of a subaccount which is not seeded yet are kept for seed.
+func (t *PositionTracker) bookFill(subAccount string, fill models.Fill) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seededAt, ok := t.seededAt[subAccount]
+	if !ok {
+		t.early[subAccount] = append(t.early[subAccount], fill)
+		return false
+	}
+	if !isAfterSnapshot(fill, seededAt) {
+		return false
+	}
+	t.applyFill(subAccount, fill)
+	return true
+}
+
+func (t *PositionTracker) pollFutureMarks(ctx context.Context) {
+	ticker := time.NewTicker(t.markInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.refreshFutureMarks(); err != nil {
+				t.client.Stream.printf("refresh future marks: %+v", err)
+			}
+		}
+	}
+}
+
+// watch subscribes to the ticker of market once, on the connection of the hub.
+func (t *PositionTracker) watch(ctx context.Context, market string) error {
+	t.mu.Lock()
+	if t.watched[market] {
+		t.mu.Unlock()
+		return nil
+	}
+	t.watched[market] = true
+	t.mu.Unlock()
+
+	tickersC, err := t.hub.SubscribeToTickers(ctx, market)
+	if err != nil {
+		t.mu.Lock()
+		delete(t.watched, market)
+		t.mu.Unlock()
+		return errors.WithStack(err)
+	}
+
+	go func() {
+		for ticker := range tickersC {
+			select {
+			case t.markC <- ticker:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (t *PositionTracker) isFuture(market string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.futures[market]
+}
+
+func (t *PositionTracker) refreshFutureMarks() error {
+	futures, err := t.client.Futures.GetFutures()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	t.mu.Lock()
+	for _, future := range futures {
+		t.futures[future.Name] = true
+	}
+	t.mu.Unlock()
+
+	for _, future := range futures {
+		t.mark(future.Name, future.Mark)
+	}
+	return nil
+}
+
+func (t *PositionTracker) mark(market string, price decimal.Decimal) {
+	if !price.IsPositive() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.marks[market] = price
+	now := time.Now()
+	for key, position := range t.positions {
+		if key.market != market {
+			continue
+		}
+		position.MarkPrice = price
+		position.UnrealizedPnl = unrealizedPnl(position)
+		position.UpdatedAt = now
+	}
+}
+
+// applyFill moves the position by the fill with the average cost method and books the fee,
+// fees paid in the base currency are valued at the fill price. Callers hold t.mu.
+func (t *PositionTracker) applyFill(subAccount string, fill models.Fill) {
+	key := positionKey{subAccount: subAccount, market: fill.Market}
+	position, ok := t.positions[key]
+	if !ok {
+		position = &TrackedPosition{
+			SubAccount: subAccount,
+			Market:     fill.Market,
+			MarkPrice:  t.marks[fill.Market],
+		}
+		t.positions[key] = position
+	}
+
+	size := fill.Size
+	if fill.Side == models.Sell {
+		size = size.Neg()
+	}
+
+	net := position.NetSize
+	switch {
+	case net.IsZero() || net.Sign() == size.Sign():
+		total := net.Abs().Add(fill.Size)
+		position.EntryPrice = position.EntryPrice.Mul(net.Abs()).Add(fill.Price.Mul(fill.Size)).Div(total)
+	default:
+		closed := decimal.Min(net.Abs(), fill.Size)
+		pnl := fill.Price.Sub(position.EntryPrice).Mul(closed)
+		if net.IsNegative() {
+			pnl = pnl.Neg()
+		}
+		position.RealizedPnl = position.RealizedPnl.Add(pnl)
+		if fill.Size.GreaterThan(net.Abs()) {
+			position.EntryPrice = fill.Price
+		}
+	}
+	position.NetSize = net.Add(size)
+	if position.NetSize.IsZero() {
+		position.EntryPrice = decimal.Zero
+	}
+
+	fee := decimal.NewFromFloat(fill.Fee)
+	if fill.FeeCurrency != "" && fill.FeeCurrency == fill.BaseCurrency {
+		fee = fee.Mul(fill.Price)
+	}
+	position.Fees = position.Fees.Add(fee)
+	position.RealizedPnl = position.RealizedPnl.Sub(fee)
+	if position.MarkPrice.IsZero() {
+		position.MarkPrice = fill.Price
+	}
+	position.UnrealizedPnl = unrealizedPnl(position)
+	position.UpdatedAt = time.Now()
+
+	t.applyBalances(subAccount, fill, size)
+}
+
+// applyBalances moves balances of the coins of a spot fill.
+func (t *PositionTracker) applyBalances(subAccount string, fill models.Fill, size decimal.Decimal) {
+	if fill.BaseCurrency == "" || fill.QuoteCurrency == "" {
+		return
+	}
+
+	coins, ok := t.balances[subAccount]
+	if !ok {
+		coins = make(map[string]*models.Balance)
+		t.balances[subAccount] = coins
+	}
+	move := func(coin string, amount decimal.Decimal) {
+		balance, ok := coins[coin]
+		if !ok {
+			balance = &models.Balance{Coin: coin}
+			coins[coin] = balance
+		}
+		balance.Total = balance.Total.Add(amount)
+		balance.Free = balance.Free.Add(amount)
+	}
+
+	move(fill.BaseCurrency, size)
+	move(fill.QuoteCurrency, size.Mul(fill.Price).Neg())
+	if fill.FeeCurrency != "" {
+		move(fill.FeeCurrency, decimal.NewFromFloat(fill.Fee).Neg())
+	}
+}
+
+func unrealizedPnl(position *TrackedPosition) decimal.Decimal {
+	if position.NetSize.IsZero() || position.MarkPrice.IsZero() {
+		return decimal.Zero
+	}
+	return position.MarkPrice.Sub(position.EntryPrice).Mul(position.NetSize)
+}
+
+func (t *PositionTracker) Position(subAccount, market string) (*TrackedPosition, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	position, ok := t.positions[positionKey{subAccount: subAccount, market: market}]
+	if !ok {
+		return nil, false
+	}
+	p := *position
+	return &p, true
+}
+
+// Positions returns the positions of every subaccount sorted by subaccount and market.
+func (t *PositionTracker) Positions() []*TrackedPosition {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]*TrackedPosition, 0, len(t.positions))
+	for _, position := range t.positions {
+		p := *position
+		result = append(result, &p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SubAccount != result[j].SubAccount {
+			return result[i].SubAccount < result[j].SubAccount
+		}
+		return result[i].Market < result[j].Market
+	})
+	return result
+}
+
+// MarketPnL sums the positions of market over every subaccount.
+func (t *PositionTracker) MarketPnL(market string) PnL {
+	return t.sum(func(key positionKey) bool {
+		return key.market == market
+	})
+}
+
+func (t *PositionTracker) SubAccountPnL(subAccount string) PnL {
+	return t.sum(func(key positionKey) bool {
+		return key.subAccount == subAccount
+	})
+}
+
+func (t *PositionTracker) TotalPnL() PnL {
+	return t.sum(func(key positionKey) bool {
+		return true
+	})
+}
+
+func (t *PositionTracker) sum(filter func(key positionKey) bool) PnL {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var pnl PnL
+	for key, position := range t.positions {
+		if !filter(key) {
+			continue
+		}
+		pnl.Realized = pnl.Realized.Add(position.RealizedPnl)
+		pnl.Unrealized = pnl.Unrealized.Add(position.UnrealizedPnl)
+		pnl.Fees = pnl.Fees.Add(position.Fees)
+	}
+	return pnl
+}
+
+// Balances returns the balances of subAccount sorted by coin.
+func (t *PositionTracker) Balances(subAccount string) []*models.Balance {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]*models.Balance, 0, len(t.balances[subAccount]))
+	for _, balance := range t.balances[subAccount] {
+		b := *balance
+		result = append(result, &b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Coin < result[j].Coin
+	})
+	return result
+}
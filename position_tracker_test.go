@@ -0,0 +1,201 @@
+package goftx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestPositionTracker(t *testing.T) {
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /positions": func(r *http.Request) (interface{}, error) {
+			return []models.Position{
+				{Future: "BTC-PERP", NetSize: decimal.NewFromInt(1), EntryPrice: decimal.NewFromInt(9000)},
+				{Future: "ETH-PERP"},
+			}, nil
+		},
+		"GET /wallet/balances": func(r *http.Request) (interface{}, error) {
+			return []models.Balance{{Coin: "USD", Free: decimal.NewFromInt(1000), Total: decimal.NewFromInt(1000)}}, nil
+		},
+	})
+	defer api.Close()
+
+	type subscription struct {
+		req  models.WSRequest
+		conn *websocket.Conn
+	}
+	subscriptionsC := make(chan subscription, 8)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe {
+			subscriptionsC <- subscription{req: req, conn: conn}
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tracker := NewPositionTracker(ftx)
+	require.NoError(t, tracker.Start(ctx))
+
+	conns := make(map[models.Channel]*websocket.Conn)
+	for len(conns) < 2 {
+		select {
+		case sub := <-subscriptionsC:
+			if sub.req.Channel == models.TickerChannel {
+				require.Equal(t, "BTC-PERP", sub.req.Market)
+			}
+			conns[sub.req.Channel] = sub.conn
+		case <-time.After(2 * time.Second):
+			t.Fatal("no subscription")
+		}
+	}
+
+	require.NoError(t, conns[models.TickerChannel].WriteJSON(map[string]interface{}{
+		"channel": models.TickerChannel,
+		"market":  "BTC-PERP",
+		"type":    models.Update,
+		"data":    map[string]interface{}{"bid": 9090, "ask": 9110, "last": 9100},
+	}))
+	require.Eventually(t, func() bool {
+		return tracker.MarketPnL("BTC-PERP").Unrealized.Equal(decimal.NewFromInt(100))
+	}, 2*time.Second, 10*time.Millisecond)
+
+	writeFill := func(fill map[string]interface{}) {
+		require.NoError(t, conns[models.FillsChannel].WriteJSON(map[string]interface{}{
+			"channel": models.FillsChannel,
+			"type":    models.Update,
+			"data":    fill,
+		}))
+	}
+	// flips the long into a short at 9200
+	writeFill(map[string]interface{}{
+		"id": 1, "market": "BTC-PERP", "side": "sell", "price": 9200, "size": 2, "fee": 1.5, "feeCurrency": "USD",
+	})
+	// spot buy with the fee paid in the base currency
+	writeFill(map[string]interface{}{
+		"id": 2, "market": "ETH/USD", "side": "buy", "price": 100, "size": 1, "fee": 0.001, "feeCurrency": "ETH",
+		"baseCurrency": "ETH", "quoteCurrency": "USD",
+	})
+
+	require.Eventually(t, func() bool {
+		_, ok := tracker.Position("", "ETH/USD")
+		return ok
+	}, 2*time.Second, 10*time.Millisecond)
+
+	btc, ok := tracker.Position("", "BTC-PERP")
+	require.True(t, ok)
+	require.True(t, btc.NetSize.Equal(decimal.NewFromInt(-1)))
+	require.True(t, btc.EntryPrice.Equal(decimal.NewFromInt(9200)))
+	require.Equal(t, "198.5", btc.RealizedPnl.String())
+	require.True(t, btc.UnrealizedPnl.Equal(decimal.NewFromInt(100)))
+
+	eth, ok := tracker.Position("", "ETH/USD")
+	require.True(t, ok)
+	require.Equal(t, "0.1", eth.Fees.String())
+	require.Equal(t, "-0.1", eth.RealizedPnl.String())
+
+	total := tracker.TotalPnL()
+	require.Equal(t, "198.4", total.Realized.String())
+	require.Equal(t, "1.6", total.Fees.String())
+	require.Equal(t, "298.4", total.Total().String())
+	require.Equal(t, total, tracker.SubAccountPnL(""))
+
+	balances := tracker.Balances("")
+	require.Len(t, balances, 2)
+	require.Equal(t, "ETH", balances[0].Coin)
+	require.Equal(t, "0.999", balances[0].Total.String())
+	require.Equal(t, "900", balances[1].Total.String())
+
+	require.Len(t, tracker.Positions(), 2)
+
+	// the ticker of the spot market is subscribed on the connection of the first ticker
+	select {
+	case sub := <-subscriptionsC:
+		require.Equal(t, models.TickerChannel, sub.req.Channel)
+		require.Equal(t, "ETH/USD", sub.req.Market)
+		require.Equal(t, conns[models.TickerChannel], sub.conn)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no ticker subscription of a traded market")
+	}
+	require.Len(t, ts.handshakeHeaders(), 2)
+}
+
+func TestPositionTracker_Seed(t *testing.T) {
+	gate := make(chan struct{})
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /positions": func(r *http.Request) (interface{}, error) {
+			<-gate
+			if r.Header.Get("FTX-SUBACCOUNT") != "sub" {
+				return nil, errors.New("positions of another subaccount")
+			}
+			return []models.Position{{Future: "BTC-PERP", NetSize: decimal.NewFromInt(1), EntryPrice: decimal.NewFromInt(9000)}}, nil
+		},
+		"GET /wallet/balances": func(r *http.Request) (interface{}, error) {
+			return []models.Balance{}, nil
+		},
+	})
+	defer api.Close()
+
+	fillsC := make(chan *websocket.Conn, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op == models.Subscribe && req.Channel == models.FillsChannel {
+			fillsC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tracker := NewPositionTracker(ftx, WithPositionSubAccounts("sub"))
+	errC := make(chan error, 1)
+	go func() {
+		errC <- tracker.Start(ctx)
+	}()
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-fillsC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fills subscription")
+	}
+	// both fills arrive while the positions are requested, only the second one is newer than the snapshot
+	for id, at := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)} {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"channel": models.FillsChannel,
+			"type":    models.Update,
+			"data": map[string]interface{}{
+				"id": id, "market": "BTC-PERP", "side": "buy", "price": 9000, "size": 1,
+				"time": float64(at.UnixNano()) / float64(time.Second),
+			},
+		}))
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+
+	select {
+	case err := <-errC:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("tracker is not started")
+	}
+
+	position, ok := tracker.Position("sub", "BTC-PERP")
+	require.True(t, ok)
+	require.True(t, position.NetSize.Equal(decimal.NewFromInt(2)))
+}
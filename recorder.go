@@ -0,0 +1,211 @@
+package goftx
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	recordFileExt        = ".jsonl"
+	recordGzipExt        = ".gz"
+	recordTimeFormat     = "20060102T150405.000000000"
+	defaultRecordPrefix  = "ftx"
+	defaultRecordMaxSize = int64(256 << 20)
+)
+
+// RecordedMessage is a single line of a record file: a raw websocket frame and its local receive time.
+// Frames of private channels keep the subaccount of their connection, so replayed fills and orders are tagged as live ones.
+type RecordedMessage struct {
+	Time       time.Time       `json:"time"`
+	SubAccount string          `json:"subAccount,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type RecorderOption func(r *Recorder)
+
+// WithRecorderPrefix sets the file name prefix of record files.
+func WithRecorderPrefix(prefix string) RecorderOption {
+	return func(r *Recorder) {
+		r.prefix = prefix
+	}
+}
+
+// WithRecorderMaxSize rotates the record file after size bytes of uncompressed data.
+func WithRecorderMaxSize(size int64) RecorderOption {
+	return func(r *Recorder) {
+		r.maxSize = size
+	}
+}
+
+// WithRecorderRotation rotates the record file every interval.
+func WithRecorderRotation(interval time.Duration) RecorderOption {
+	return func(r *Recorder) {
+		r.rotation = interval
+	}
+}
+
+// WithRecorderCompression gzips record files.
+func WithRecorderCompression() RecorderOption {
+	return func(r *Recorder) {
+		r.compress = true
+	}
+}
+
+// Recorder writes raw websocket frames to rotating JSONL files.
+// File names contain the time of their first message, so sorting them by name
+// gives the order they have to be replayed in.
+type Recorder struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string
+	maxSize  int64
+	rotation time.Duration
+	compress bool
+	file     *os.File
+	gz       *gzip.Writer
+	w        *bufio.Writer
+	size     int64
+	openedAt time.Time
+}
+
+func NewRecorder(dir string, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		dir:     dir,
+		prefix:  defaultRecordPrefix,
+		maxSize: defaultRecordMaxSize,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return r, nil
+}
+
+func (r *Recorder) Record(receivedAt time.Time, data []byte) error {
+	return r.write(RecordedMessage{
+		Time: receivedAt,
+		Data: data,
+	})
+}
+
+func (r *Recorder) write(message RecordedMessage) error {
+	receivedAt := message.Time
+	line, err := json.Marshal(message)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.needRotation(receivedAt, len(line)) {
+		err = r.rotate(receivedAt)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	n, err := r.w.Write(line)
+	r.size += int64(n)
+	return errors.WithStack(err)
+}
+
+// Flush writes buffered messages to the current file.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.w == nil {
+		return nil
+	}
+
+	err := r.w.Flush()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if r.gz != nil {
+		return errors.WithStack(r.gz.Flush())
+	}
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closeFile()
+}
+
+func (r *Recorder) needRotation(now time.Time, size int) bool {
+	if r.file == nil {
+		return true
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(size) > r.maxSize {
+		return true
+	}
+	return r.rotation > 0 && now.Sub(r.openedAt) >= r.rotation
+}
+
+func (r *Recorder) rotate(now time.Time) error {
+	err := r.closeFile()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	name := fmt.Sprintf("%s-%s%s", r.prefix, now.UTC().Format(recordTimeFormat), recordFileExt)
+	if r.compress {
+		name += recordGzipExt
+	}
+
+	file, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	r.file = file
+	r.size = 0
+	r.openedAt = now
+	if r.compress {
+		r.gz = gzip.NewWriter(file)
+		r.w = bufio.NewWriter(r.gz)
+	} else {
+		r.w = bufio.NewWriter(file)
+	}
+
+	return nil
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.w.Flush()
+	if err == nil && r.gz != nil {
+		err = r.gz.Close()
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	r.file = nil
+	r.gz = nil
+	r.w = nil
+
+	return errors.WithStack(err)
+}
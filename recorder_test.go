@@ -0,0 +1,38 @@
+package goftx
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_Rotation(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder, err := NewRecorder(dir, WithRecorderCompression(), WithRecorderMaxSize(200))
+	require.NoError(t, err)
+
+	started := time.Now()
+	for i := 0; i < 10; i++ {
+		err = recorder.Record(started.Add(time.Duration(i)*time.Millisecond), []byte(`{"channel":"ticker","market":"BTC-PERP","type":"update","data":{}}`))
+		require.NoError(t, err)
+	}
+	require.NoError(t, recorder.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "ftx-*.jsonl.gz"))
+	require.NoError(t, err)
+	require.True(t, len(files) > 1)
+
+	count := 0
+	for _, file := range files {
+		err = readRecordFile(file, func(record *RecordedMessage) bool {
+			require.Equal(t, started.Add(time.Duration(count)*time.Millisecond).UnixNano(), record.Time.UnixNano())
+			count++
+			return true
+		})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 10, count)
+}
@@ -0,0 +1,254 @@
+package goftx
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const replayMaxLineSize = 64 << 20
+
+type ReplayOption func(r *Replay)
+
+// WithReplaySpeed sets the replay speed: 1 replays at the original pace, 10 ten times faster
+// and 0 as fast as the consumer reads.
+func WithReplaySpeed(speed float64) ReplayOption {
+	return func(r *Replay) {
+		r.speed = speed
+	}
+}
+
+// Replay is a Stream source that reads files written by Recorder and emits
+// the same typed responses as Stream does for the live feed.
+type Replay struct {
+	files    []string
+	speed    float64
+	channels *channelRegistry
+}
+
+func NewReplay(files []string, opts ...ReplayOption) *Replay {
+	r := &Replay{
+		files:    files,
+		speed:    1,
+		channels: newChannelRegistry(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// RegisterChannel adds a custom channel decoder, see Stream.RegisterChannel.
+func (r *Replay) RegisterChannel(definition ChannelDefinition) error {
+	return r.channels.register(definition)
+}
+
+// Subscribe replays decoded messages of any registered channel.
+func (r *Replay) Subscribe(ctx context.Context, channel models.Channel, symbols ...string) (chan interface{}, error) {
+	if _, ok := r.channels.get(channel); !ok {
+		return nil, errors.Errorf("channel %v is not registered", channel)
+	}
+
+	eventsC, err := r.serve(ctx, replayRequests(channel, symbols)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardValues(ctx, eventsC), nil
+}
+
+func (r *Replay) serve(ctx context.Context, requests ...models.WSRequest) (chan event, error) {
+	for _, file := range r.files {
+		if _, err := os.Stat(file); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	eventsC := make(chan event, 1)
+	go func() {
+		defer close(eventsC)
+
+		var first, started time.Time
+		for _, file := range r.files {
+			err := readRecordFile(file, func(record *RecordedMessage) bool {
+				// frames are decoded and tagged the same way as serveConn does for the live feed
+				header, response, err := r.channels.decodeFrame(record.Data)
+				if err != nil || !matchRequests(requests, header) {
+					return true
+				}
+				response.channel = header.Channel
+				response.market = header.Market
+				response.tagSubAccount(record.SubAccount)
+
+				if first.IsZero() {
+					first, started = record.Time, time.Now()
+				}
+				if !r.wait(ctx, started, record.Time.Sub(first)) {
+					return false
+				}
+
+				select {
+				case eventsC <- response:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			if err != nil || ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return eventsC, nil
+}
+
+// wait sleeps until offset of the recording, scaled by speed, has elapsed since started.
+func (r *Replay) wait(ctx context.Context, started time.Time, offset time.Duration) bool {
+	if r.speed <= 0 {
+		return ctx.Err() == nil
+	}
+
+	delay := time.Until(started.Add(time.Duration(float64(offset) / r.speed)))
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func readRecordFile(path string, fn func(record *RecordedMessage) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, recordGzipExt) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), replayMaxLineSize)
+	for scanner.Scan() {
+		record := &RecordedMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			continue
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+
+	return errors.WithStack(scanner.Err())
+}
+
+func matchRequests(requests []models.WSRequest, header frameHeader) bool {
+	switch header.Type {
+	case models.Partial, models.Update:
+	default:
+		return false
+	}
+
+	for _, req := range requests {
+		if req.Channel == header.Channel && (req.Market == "" || req.Market == header.Market) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Replay) SubscribeToFills(ctx context.Context) (chan *models.FillResponse, error) {
+	eventsC, err := r.serve(ctx, models.WSRequest{Channel: models.FillsChannel})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardFills(ctx, eventsC), nil
+}
+
+func (r *Replay) SubscribeToOrders(ctx context.Context) (chan *models.OrderResponse, error) {
+	eventsC, err := r.serve(ctx, models.WSRequest{Channel: models.OrdersChannel})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardOrders(ctx, eventsC), nil
+}
+
+func (r *Replay) SubscribeToMarkets(ctx context.Context) (chan *models.Market, error) {
+	eventsC, err := r.serve(ctx, models.WSRequest{Channel: models.MarketsChannel})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardMarkets(ctx, eventsC), nil
+}
+
+func (r *Replay) SubscribeToTickers(ctx context.Context, symbols ...string) (chan *models.TickerResponse, error) {
+	eventsC, err := r.serve(ctx, replayRequests(models.TickerChannel, symbols)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTickers(ctx, eventsC), nil
+}
+
+func (r *Replay) SubscribeToTrades(ctx context.Context, symbols ...string) (chan *models.TradeResponse, error) {
+	eventsC, err := r.serve(ctx, replayRequests(models.TradesChannel, symbols)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTrades(ctx, eventsC), nil
+}
+
+func (r *Replay) SubscribeToOrderBooks(ctx context.Context, symbols ...string) (chan *models.OrderBookResponse, error) {
+	eventsC, err := r.serve(ctx, replayRequests(models.OrderBookChannel, symbols)...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardOrderBooks(ctx, eventsC), nil
+}
+
+// replayRequests builds filters for symbols, no symbols replays every market of the channel.
+func replayRequests(channel models.Channel, symbols []string) []models.WSRequest {
+	if len(symbols) == 0 {
+		return []models.WSRequest{{Channel: channel}}
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: channel,
+			Market:  symbol,
+		})
+	}
+	return requests
+}
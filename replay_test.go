@@ -0,0 +1,121 @@
+package goftx
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestReplay_SubscribeToTickers(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		for i := 1; i <= 3; i++ {
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": req.Channel,
+				"market":  req.Market,
+				"type":    models.Update,
+				"data": map[string]interface{}{
+					"bid": 100 + i, "ask": 101 + i, "bidSize": 1, "askSize": 2, "last": 100.5, "time": 1600000000.123456 + float64(i)/100,
+				},
+			})
+		}
+	})
+	defer ts.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, WithRecorderCompression())
+	require.NoError(t, err)
+
+	ftx := newTestClient(ts)
+	ftx.Stream.SetRecorder(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	data, err := ftx.Stream.SubscribeToTickers(ctx, "BTC-PERP")
+	require.NoError(t, err)
+
+	var live []*models.TickerResponse
+	for len(live) < 3 {
+		select {
+		case msg := <-data:
+			live = append(live, msg)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no tickers received")
+		}
+	}
+	cancel()
+	ftx.Stream.SetRecorder(nil)
+	require.NoError(t, recorder.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.gz"))
+	require.NoError(t, err)
+
+	replay := NewReplay(files, WithReplaySpeed(0))
+	replayed, err := replay.SubscribeToTickers(context.Background(), "BTC-PERP")
+	require.NoError(t, err)
+
+	var result []*models.TickerResponse
+	for msg := range replayed {
+		result = append(result, msg)
+	}
+	require.Equal(t, live, result)
+
+	replayed, err = replay.SubscribeToTickers(context.Background(), "ETH-PERP")
+	require.NoError(t, err)
+	_, ok := <-replayed
+	require.False(t, ok)
+}
+
+func TestReplay_SubscribeToFills_SubAccounts(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": models.FillsChannel,
+			"type":    models.Update,
+			"data":    map[string]interface{}{"id": 1, "market": "BTC-PERP", "price": 1, "size": 1},
+		})
+	})
+	defer ts.Close()
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	require.NoError(t, err)
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+	ftx.Stream.SetRecorder(recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	data, err := ftx.Stream.SubscribeToFills(ctx, "alpha")
+	require.NoError(t, err)
+
+	var live *models.FillResponse
+	select {
+	case live = <-data:
+		require.Equal(t, "alpha", live.SubAccount)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fill received")
+	}
+	cancel()
+	ftx.Stream.SetRecorder(nil)
+	require.NoError(t, recorder.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	require.NoError(t, err)
+
+	replayed, err := NewReplay(files, WithReplaySpeed(0)).SubscribeToFills(context.Background())
+	require.NoError(t, err)
+	var result []*models.FillResponse
+	for msg := range replayed {
+		result = append(result, msg)
+	}
+	require.Equal(t, []*models.FillResponse{live}, result)
+}
@@ -0,0 +1,149 @@
+package goftx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+// testServer is a local websocket endpoint that speaks enough of the FTX protocol
+// to exercise Stream without network access.
+type testServer struct {
+	*httptest.Server
+	requestsC chan models.WSRequest
+
+	mu      sync.Mutex
+	conns   []*websocket.Conn
+	headers []http.Header
+	// rejects is how many of the next handshakes are answered with an error.
+	rejects int
+}
+
+type testHandler func(conn *websocket.Conn, req models.WSRequest)
+
+func newTestServer(t *testing.T, handler testHandler) *testServer {
+	ts := &testServer{
+		requestsC: make(chan models.WSRequest, 1024),
+	}
+	upgrader := websocket.Upgrader{EnableCompression: true}
+
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		if ts.rejects > 0 {
+			ts.rejects--
+			ts.mu.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ts.mu.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Logf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		ts.mu.Lock()
+		ts.conns = append(ts.conns, conn)
+		ts.headers = append(ts.headers, r.Header.Clone())
+		ts.mu.Unlock()
+
+		for {
+			var req models.WSRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			select {
+			case ts.requestsC <- req:
+			default:
+			}
+
+			switch req.Op {
+			case models.Ping:
+				_ = conn.WriteJSON(map[string]interface{}{"type": models.Pong})
+			case models.Subscribe:
+				_ = conn.WriteJSON(map[string]interface{}{
+					"type":    models.Subscribed,
+					"channel": req.Channel,
+					"market":  req.Market,
+				})
+			}
+
+			if handler != nil {
+				handler(conn, req)
+			}
+		}
+	}))
+
+	return ts
+}
+
+func (ts *testServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func (ts *testServer) handshakeHeaders() []http.Header {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.headers
+}
+
+// dropConnections closes every accepted connection without a close frame
+// and rejects the next rejects handshakes.
+func (ts *testServer) dropConnections(rejects ...int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if len(rejects) > 0 {
+		ts.rejects = rejects[0]
+	}
+
+	for _, conn := range ts.conns {
+		_ = conn.UnderlyingConn().Close()
+	}
+	ts.conns = nil
+}
+
+func newTestClient(ts *testServer, opts ...Option) *Client {
+	client := New(opts...)
+	client.Stream.url = ts.wsURL()
+	return client
+}
+
+// testRoute answers a REST request with the result of a successful response or an error.
+type testRoute func(r *http.Request) (interface{}, error)
+
+// newTestAPI serves REST routes keyed by "METHOD /path" wrapped into the FTX response envelope.
+func newTestAPI(t *testing.T, routes map[string]testRoute) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Logf("unexpected request %v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(Response{Error: "Not Found"})
+			return
+		}
+
+		result, err := route(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(Response{Error: err.Error()})
+			return
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Errorf("marshal result: %v", err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{Success: true, Result: data})
+	}))
+}
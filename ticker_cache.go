@@ -0,0 +1,314 @@
+package goftx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	tickerStaleAfter   = time.Second * 10
+	tickerChangeBuffer = 16
+)
+
+var bps = decimal.NewFromInt(10000)
+
+type TickerSource string
+
+const (
+	TickerFromStream = TickerSource("stream")
+	TickerFromREST   = TickerSource("rest")
+)
+
+// CachedTicker is the latest known ticker of a market.
+type CachedTicker struct {
+	Market    string
+	Ticker    models.Ticker
+	Source    TickerSource
+	UpdatedAt time.Time
+}
+
+// Mid returns the middle of bid and ask, or the last price if the market has no bid or ask.
+func (t CachedTicker) Mid() decimal.Decimal {
+	if t.Ticker.Bid.IsPositive() && t.Ticker.Ask.IsPositive() {
+		return t.Ticker.Bid.Add(t.Ticker.Ask).Div(decimal.NewFromInt(2))
+	}
+	return t.Ticker.Last
+}
+
+// TickerChange is sent to change subscribers when the mid moved more than their threshold.
+// Previous is the ticker of the previous notification, or the baseline of the subscription.
+type TickerChange struct {
+	Market   string
+	Previous CachedTicker
+	Current  CachedTicker
+	MoveBps  decimal.Decimal
+}
+
+type TickerCacheOption func(c *TickerCache)
+
+// WithTickerStaleAfter sets how long a ticker is fresh. Stale tickers are refreshed with Markets.GetMarkets.
+// A non-positive staleAfter keeps the default.
+func WithTickerStaleAfter(staleAfter time.Duration) TickerCacheOption {
+	return func(c *TickerCache) {
+		if staleAfter > 0 {
+			c.staleAfter = staleAfter
+		}
+	}
+}
+
+// TickerCache keeps the latest ticker of markets from a single ticker subscription.
+type TickerCache struct {
+	client     *Client
+	staleAfter time.Duration
+
+	mu       sync.RWMutex
+	markets  map[string]bool
+	tickers  map[string]CachedTicker
+	watchers map[int]*tickerWatcher
+	nextID   int
+}
+
+type tickerWatcher struct {
+	markets      map[string]bool
+	thresholdBps decimal.Decimal
+	reference    map[string]CachedTicker
+	changesC     chan *TickerChange
+}
+
+func NewTickerCache(client *Client, opts ...TickerCacheOption) *TickerCache {
+	c := &TickerCache{
+		client:     client,
+		staleAfter: tickerStaleAfter,
+		tickers:    make(map[string]CachedTicker),
+		watchers:   make(map[int]*tickerWatcher),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Start seeds the cache with Markets.GetMarkets and keeps it up to date from the ticker channel until ctx is done.
+// Without markets every market returned by GetMarkets is tracked.
+// If the subscription ends, tickers are refreshed with GetMarkets once they get stale.
+func (c *TickerCache) Start(ctx context.Context, markets ...string) error {
+	all, err := c.client.Markets.GetMarkets()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(markets) == 0 {
+		for _, market := range all {
+			markets = append(markets, market.Name)
+		}
+	}
+	if len(markets) == 0 {
+		return errors.New("markets is missing")
+	}
+
+	c.mu.Lock()
+	c.markets = make(map[string]bool, len(markets))
+	for _, market := range markets {
+		c.markets[market] = true
+	}
+	c.mu.Unlock()
+
+	c.applyMarkets(all, nil)
+
+	tickersC, err := c.client.Stream.SubscribeToTickers(ctx, markets...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	go c.run(ctx, tickersC)
+
+	return nil
+}
+
+func (c *TickerCache) run(ctx context.Context, tickersC chan *models.TickerResponse) {
+	staleTicker := time.NewTicker(c.staleAfter / 2)
+	defer staleTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ticker, ok := <-tickersC:
+			if !ok {
+				tickersC = nil
+				continue
+			}
+			c.set(CachedTicker{
+				Market:    ticker.Symbol,
+				Ticker:    ticker.Ticker,
+				Source:    TickerFromStream,
+				UpdatedAt: time.Now(),
+			})
+		case <-staleTicker.C:
+			stale := c.Stale()
+			if len(stale) == 0 {
+				continue
+			}
+			markets, err := c.client.Markets.GetMarkets()
+			if err != nil {
+				c.client.Stream.printf("refresh stale tickers: %v", err)
+				continue
+			}
+			only := make(map[string]bool, len(stale))
+			for _, market := range stale {
+				only[market] = true
+			}
+			c.applyMarkets(markets, only)
+		}
+	}
+}
+
+// applyMarkets stores tickers of the REST markets that are tracked and in only, all tracked ones if only is nil.
+func (c *TickerCache) applyMarkets(markets []*models.Market, only map[string]bool) {
+	now := time.Now()
+	for _, market := range markets {
+		c.mu.RLock()
+		tracked := c.markets[market.Name]
+		c.mu.RUnlock()
+		if !tracked || (only != nil && !only[market.Name]) {
+			continue
+		}
+
+		c.set(CachedTicker{
+			Market: market.Name,
+			Ticker: models.Ticker{
+				Bid:  market.Bid,
+				Ask:  market.Ask,
+				Last: market.Last,
+				Time: models.FTXTime{Time: now},
+			},
+			Source:    TickerFromREST,
+			UpdatedAt: now,
+		})
+	}
+}
+
+func (c *TickerCache) set(ticker CachedTicker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tickers[ticker.Market] = ticker
+
+	for _, watcher := range c.watchers {
+		if watcher.markets != nil && !watcher.markets[ticker.Market] {
+			continue
+		}
+		reference, ok := watcher.reference[ticker.Market]
+		if !ok {
+			watcher.reference[ticker.Market] = ticker
+			continue
+		}
+
+		referenceMid := reference.Mid()
+		if !referenceMid.IsPositive() {
+			watcher.reference[ticker.Market] = ticker
+			continue
+		}
+		move := ticker.Mid().Sub(referenceMid).Abs().Div(referenceMid).Mul(bps)
+		if move.LessThanOrEqual(watcher.thresholdBps) {
+			continue
+		}
+
+		watcher.reference[ticker.Market] = ticker
+		select {
+		case watcher.changesC <- &TickerChange{
+			Market:   ticker.Market,
+			Previous: reference,
+			Current:  ticker,
+			MoveBps:  move,
+		}:
+		default:
+		}
+	}
+}
+
+func (c *TickerCache) Get(market string) (CachedTicker, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ticker, ok := c.tickers[market]
+	return ticker, ok
+}
+
+// Snapshot returns a copy of the cache keyed by market name.
+func (c *TickerCache) Snapshot() map[string]CachedTicker {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]CachedTicker, len(c.tickers))
+	for market, ticker := range c.tickers {
+		result[market] = ticker
+	}
+	return result
+}
+
+// IsStale reports whether the ticker of market is unknown or older than the stale timeout.
+func (c *TickerCache) IsStale(market string) bool {
+	ticker, ok := c.Get(market)
+	return !ok || time.Since(ticker.UpdatedAt) > c.staleAfter
+}
+
+// Stale returns the tracked markets whose tickers are stale.
+func (c *TickerCache) Stale() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var result []string
+	for market := range c.markets {
+		ticker, ok := c.tickers[market]
+		if !ok || time.Since(ticker.UpdatedAt) > c.staleAfter {
+			result = append(result, market)
+		}
+	}
+	return result
+}
+
+// SubscribeToChanges notifies when the mid of a market moves more than thresholdBps basis points
+// from the previous notification. Without markets all tracked markets are watched.
+// Notifications are dropped while the channel is full, it is closed when ctx is done.
+func (c *TickerCache) SubscribeToChanges(ctx context.Context, thresholdBps decimal.Decimal, markets ...string) chan *TickerChange {
+	watcher := &tickerWatcher{
+		thresholdBps: thresholdBps,
+		reference:    make(map[string]CachedTicker),
+		changesC:     make(chan *TickerChange, tickerChangeBuffer),
+	}
+	if len(markets) > 0 {
+		watcher.markets = make(map[string]bool, len(markets))
+		for _, market := range markets {
+			watcher.markets[market] = true
+		}
+	}
+
+	c.mu.Lock()
+	for market, ticker := range c.tickers {
+		if watcher.markets == nil || watcher.markets[market] {
+			watcher.reference[market] = ticker
+		}
+	}
+	id := c.nextID
+	c.nextID++
+	c.watchers[id] = watcher
+	c.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.watchers, id)
+		close(watcher.changesC)
+	}()
+
+	return watcher.changesC
+}
@@ -0,0 +1,114 @@
+package goftx
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func TestTickerCache(t *testing.T) {
+	var calls int64
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /markets": func(r *http.Request) (interface{}, error) {
+			n := atomic.AddInt64(&calls, 1)
+			return []models.Market{
+				{Name: "BTC-PERP", Bid: decimal.NewFromInt(100), Ask: decimal.NewFromInt(102), Last: decimal.NewFromInt(101)},
+				{Name: "ETH-PERP", Bid: decimal.NewFromInt(10), Ask: decimal.NewFromInt(11), Last: decimal.NewFromInt(10 + n)},
+				{Name: "SOL-PERP", Bid: decimal.NewFromInt(1), Ask: decimal.NewFromInt(2), Last: decimal.NewFromInt(1)},
+			}, nil
+		},
+	})
+	defer api.Close()
+
+	// the connection is handed over once both subscriptions are acknowledged,
+	// so that the test is its only writer
+	connC := make(chan *websocket.Conn, 1)
+	subscriptions := 0
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		subscriptions++
+		if subscriptions == 2 {
+			connC <- conn
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+	ftx.apiURL = api.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache := NewTickerCache(ftx, WithTickerStaleAfter(300*time.Millisecond))
+	require.NoError(t, cache.Start(ctx, "BTC-PERP", "ETH-PERP"))
+
+	btc, ok := cache.Get("BTC-PERP")
+	require.True(t, ok)
+	require.Equal(t, TickerFromREST, btc.Source)
+	require.True(t, btc.Mid().Equal(decimal.NewFromInt(101)))
+	_, ok = cache.Get("SOL-PERP")
+	require.False(t, ok)
+	require.Len(t, cache.Snapshot(), 2)
+
+	changes := cache.SubscribeToChanges(ctx, decimal.NewFromInt(10), "BTC-PERP")
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-connC:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no subscription")
+	}
+	writeTicker := func(bid, ask float64) {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"channel": models.TickerChannel,
+			"market":  "BTC-PERP",
+			"type":    models.Update,
+			"data":    map[string]interface{}{"bid": bid, "ask": ask, "last": bid},
+		}))
+	}
+	writeTicker(101, 101.1)
+	writeTicker(102, 102)
+
+	select {
+	case change := <-changes:
+		require.Equal(t, "BTC-PERP", change.Market)
+		require.Equal(t, TickerFromREST, change.Previous.Source)
+		require.Equal(t, TickerFromStream, change.Current.Source)
+		require.True(t, change.Current.Mid().Equal(decimal.NewFromInt(102)))
+		require.Equal(t, "99.0099", change.MoveBps.StringFixed(4))
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change received")
+	}
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected change %+v", change)
+	default:
+	}
+
+	require.Eventually(t, func() bool {
+		eth, ok := cache.Get("ETH-PERP")
+		return ok && eth.Source == TickerFromREST && eth.Ticker.Last.GreaterThan(decimal.NewFromInt(11))
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, ok := <-changes
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWithTickerStaleAfter(t *testing.T) {
+	ftx := New()
+	require.Equal(t, tickerStaleAfter, NewTickerCache(ftx, WithTickerStaleAfter(0)).staleAfter)
+	require.Equal(t, time.Second, NewTickerCache(ftx, WithTickerStaleAfter(time.Second)).staleAfter)
+}
@@ -0,0 +1,310 @@
+package goftx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+const (
+	validatorRefreshInterval = time.Minute
+	validatorOrderMarkets    = 10000
+)
+
+// OrderValidationError is returned for orders that the exchange would reject because of the market metadata.
+type OrderValidationError struct {
+	Market string
+	Field  string
+	Reason string
+}
+
+func (e *OrderValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("market %v: %v", e.Market, e.Reason)
+	}
+	return fmt.Sprintf("market %v: %v %v", e.Market, e.Field, e.Reason)
+}
+
+type OrderValidatorOption func(v *OrderValidator)
+
+// WithValidatorRefreshInterval sets how long the market metadata is cached.
+func WithValidatorRefreshInterval(interval time.Duration) OrderValidatorOption {
+	return func(v *OrderValidator) {
+		v.refreshInterval = interval
+	}
+}
+
+// WithValidatorMetadata makes the validator use a shared metadata cache instead of its own.
+func WithValidatorMetadata(metadata *MetadataCache) OrderValidatorOption {
+	return func(v *OrderValidator) {
+		v.metadata = metadata
+	}
+}
+
+// WithOrderValidation makes PlaceOrder, PlaceTriggerOrder and ModifyOrder validate payloads
+// against the cached market metadata before they are sent.
+func WithOrderValidation(opts ...OrderValidatorOption) Option {
+	return func(c *Client) {
+		c.validator = NewOrderValidator(c, opts...)
+	}
+}
+
+// OrderValidator checks orders against the enabled and post-only flags, the price and size increments,
+// the minimum provide size of post only orders and the price bands of futures. Trigger orders are not
+// checked against the price bands.
+type OrderValidator struct {
+	client          *Client
+	refreshInterval time.Duration
+	metadata        *MetadataCache
+
+	mu           sync.Mutex
+	orderMarkets map[int64]string
+}
+
+func NewOrderValidator(client *Client, opts ...OrderValidatorOption) *OrderValidator {
+	v := &OrderValidator{
+		client:          client,
+		refreshInterval: validatorRefreshInterval,
+		orderMarkets:    make(map[int64]string),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.metadata == nil {
+		v.metadata = NewMetadataCache(client, WithMetadataRefreshInterval(v.refreshInterval))
+	}
+	return v
+}
+
+// OrderValidator returns the validator of the client, nil if the validation is disabled.
+func (c *Client) OrderValidator() *OrderValidator {
+	return c.validator
+}
+
+// Refresh reloads the market metadata.
+func (v *OrderValidator) Refresh() error {
+	return errors.WithStack(v.metadata.Refresh())
+}
+
+// lookup returns the metadata of a market, an unknown market is a validation error.
+func (v *OrderValidator) lookup(name string) (*models.MarketMetadata, error) {
+	market, err := v.metadata.Lookup(name)
+	if errors.Is(err, errUnknownMarket) {
+		return nil, &OrderValidationError{Market: name, Reason: "is unknown"}
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return market, nil
+}
+
+// RoundPrice rounds price to the tick of market toward the passive side.
+func (v *OrderValidator) RoundPrice(market string, side models.Side, price decimal.Decimal) (decimal.Decimal, error) {
+	m, err := v.lookup(market)
+	if err != nil {
+		return decimal.Zero, errors.WithStack(err)
+	}
+	return m.RoundPrice(side, price), nil
+}
+
+// FloorSize rounds size down to the lot of market.
+func (v *OrderValidator) FloorSize(market string, size decimal.Decimal) (decimal.Decimal, error) {
+	m, err := v.lookup(market)
+	if err != nil {
+		return decimal.Zero, errors.WithStack(err)
+	}
+	return m.FloorSize(size), nil
+}
+
+func (v *OrderValidator) ValidatePlaceOrder(payload *models.PlaceOrderPayload) error {
+	market, err := v.lookup(payload.Market)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = checkTradable(market)
+	if err != nil {
+		return err
+	}
+
+	postOnly := payload.PostOnly != nil && *payload.PostOnly
+	if market.PostOnly && (payload.Type != models.LimitOrder || !postOnly) {
+		return &OrderValidationError{Market: market.Name, Reason: "accepts post only limit orders only"}
+	}
+
+	if payload.Type == models.LimitOrder {
+		err = checkPrice(market, "price", payload.Price)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checkSize(market, payload.Size)
+	if err != nil {
+		return err
+	}
+	// the min provide size only applies to orders that are sure to rest
+	if payload.Type == models.LimitOrder && postOnly && payload.Size.LessThan(market.MinProvideSize) {
+		return &OrderValidationError{
+			Market: market.Name,
+			Field:  "size",
+			Reason: fmt.Sprintf("%v is below the min provide size %v", payload.Size, market.MinProvideSize),
+		}
+	}
+
+	return nil
+}
+
+func (v *OrderValidator) ValidatePlaceTriggerOrder(payload *models.PlaceTriggerOrderPayload) error {
+	market, err := v.lookup(payload.Market)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = checkTradable(market)
+	if err != nil {
+		return err
+	}
+	if market.PostOnly {
+		return &OrderValidationError{Market: market.Name, Reason: "accepts post only limit orders only"}
+	}
+
+	// the price bands are checked by the exchange once the order triggers, they could have moved by then
+	if payload.TriggerPrice != nil {
+		err = checkTick(market, "triggerPrice", *payload.TriggerPrice)
+		if err != nil {
+			return err
+		}
+	}
+	if payload.OrderPrice != nil {
+		err = checkTick(market, "orderPrice", *payload.OrderPrice)
+		if err != nil {
+			return err
+		}
+	}
+
+	return checkSize(market, payload.Size)
+}
+
+// ValidateModifyOrder checks the new price and size of an order. The market of orders placed
+// through the client is remembered, the market of other orders is fetched with Orders.GetOrder.
+func (v *OrderValidator) ValidateModifyOrder(payload *models.ModifyOrderPayload, orderID int64) error {
+	if payload.Price == nil && payload.Size == nil {
+		return nil
+	}
+
+	v.mu.Lock()
+	name, ok := v.orderMarkets[orderID]
+	v.mu.Unlock()
+	if !ok {
+		order, err := v.client.Orders.GetOrder(orderID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		name = order.Market
+		v.rememberOrder(order)
+	}
+
+	market, err := v.lookup(name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = checkTradable(market)
+	if err != nil {
+		return err
+	}
+
+	if payload.Price != nil {
+		err = checkPrice(market, "price", *payload.Price)
+		if err != nil {
+			return err
+		}
+	}
+	if payload.Size != nil {
+		return checkSize(market, *payload.Size)
+	}
+
+	return nil
+}
+
+// rememberOrder keeps the market of an order for ModifyOrder, forgetting all of them once there are too many.
+func (v *OrderValidator) rememberOrder(order *models.Order) {
+	if order == nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.orderMarkets) >= validatorOrderMarkets {
+		v.orderMarkets = make(map[int64]string)
+	}
+	v.orderMarkets[order.ID] = order.Market
+}
+
+func checkTradable(market *models.MarketMetadata) error {
+	if !market.Enabled {
+		return &OrderValidationError{Market: market.Name, Reason: "is disabled"}
+	}
+	return nil
+}
+
+func checkPrice(market *models.MarketMetadata, field string, price decimal.Decimal) error {
+	err := checkTick(market, field, price)
+	if err != nil {
+		return err
+	}
+	if market.UpperBound.IsPositive() && price.GreaterThan(market.UpperBound) {
+		return &OrderValidationError{
+			Market: market.Name,
+			Field:  field,
+			Reason: fmt.Sprintf("%v is above the upper bound %v", price, market.UpperBound),
+		}
+	}
+	if market.LowerBound.IsPositive() && price.LessThan(market.LowerBound) {
+		return &OrderValidationError{
+			Market: market.Name,
+			Field:  field,
+			Reason: fmt.Sprintf("%v is below the lower bound %v", price, market.LowerBound),
+		}
+	}
+	return nil
+}
+
+func checkTick(market *models.MarketMetadata, field string, price decimal.Decimal) error {
+	if !price.IsPositive() {
+		return &OrderValidationError{Market: market.Name, Field: field, Reason: "must be positive"}
+	}
+	if !models.IsMultipleOf(price, market.PriceIncrement) {
+		return &OrderValidationError{
+			Market: market.Name,
+			Field:  field,
+			Reason: fmt.Sprintf("%v is not a multiple of the price increment %v", price, market.PriceIncrement),
+		}
+	}
+	return nil
+}
+
+func checkSize(market *models.MarketMetadata, size decimal.Decimal) error {
+	if !size.IsPositive() {
+		return &OrderValidationError{Market: market.Name, Field: "size", Reason: "must be positive"}
+	}
+	if size.LessThan(market.SizeIncrement) {
+		return &OrderValidationError{
+			Market: market.Name,
+			Field:  "size",
+			Reason: fmt.Sprintf("%v is below the size increment %v", size, market.SizeIncrement),
+		}
+	}
+	if !models.IsMultipleOf(size, market.SizeIncrement) {
+		return &OrderValidationError{
+			Market: market.Name,
+			Field:  "size",
+			Reason: fmt.Sprintf("%v is not a multiple of the size increment %v", size, market.SizeIncrement),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package goftx
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grishinsana/goftx/models"
+)
+
+func newValidatorTestAPI(t *testing.T, placed *int64) *Client {
+	api := newTestAPI(t, map[string]testRoute{
+		"GET /markets": func(r *http.Request) (interface{}, error) {
+			return []models.Market{
+				{
+					Name: "BTC-PERP", Enabled: true, PriceIncrement: decimal.NewFromInt(1),
+					SizeIncrement: decimal.RequireFromString("0.001"), MinProvideSize: decimal.RequireFromString("0.01"),
+				},
+				{
+					Name: "NEW/USD", Enabled: true, PostOnly: true, PriceIncrement: decimal.RequireFromString("0.05"),
+					SizeIncrement: decimal.NewFromInt(1), MinProvideSize: decimal.NewFromInt(1),
+				},
+				{Name: "OLD/USD", PriceIncrement: decimal.NewFromInt(1), SizeIncrement: decimal.NewFromInt(1)},
+			}, nil
+		},
+		"GET /futures": func(r *http.Request) (interface{}, error) {
+			return []models.Future{
+				{Name: "BTC-PERP", UpperBound: decimal.NewFromInt(10000), LowerBound: decimal.NewFromInt(8000)},
+			}, nil
+		},
+		"POST /orders": func(r *http.Request) (interface{}, error) {
+			atomic.AddInt64(placed, 1)
+			return models.Order{ID: 5, Market: "BTC-PERP"}, nil
+		},
+		"POST /conditional_orders": func(r *http.Request) (interface{}, error) {
+			atomic.AddInt64(placed, 1)
+			return models.TriggerOrder{ID: 7, Market: "BTC-PERP"}, nil
+		},
+		"POST /orders/5/modify": func(r *http.Request) (interface{}, error) {
+			return models.Order{ID: 6, Market: "BTC-PERP"}, nil
+		},
+	})
+	t.Cleanup(api.Close)
+
+	client := New(WithOrderValidation())
+	client.apiURL = api.URL
+	return client
+}
+
+func TestOrderValidator_PlaceOrder(t *testing.T) {
+	var placed int64
+	client := newValidatorTestAPI(t, &placed)
+
+	postOnly := true
+	ioc := true
+	tests := []struct {
+		name    string
+		payload models.PlaceOrderPayload
+		valid   bool
+		field   string
+	}{
+		{
+			name:    "valid",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.RequireFromString("0.01")},
+			valid:   true,
+		},
+		{
+			name:    "valid small ioc",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.RequireFromString("0.001"), IOC: &ioc},
+			valid:   true,
+		},
+		{
+			name:    "valid market",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.MarketOrder, Size: decimal.RequireFromString("0.001")},
+			valid:   true,
+		},
+		{
+			name:    "tick",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.RequireFromString("9000.5"), Size: decimal.NewFromInt(1)},
+			field:   "price",
+		},
+		{
+			name:    "upper bound",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.NewFromInt(10001), Size: decimal.NewFromInt(1)},
+			field:   "price",
+		},
+		{
+			name:    "lot",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.MarketOrder, Size: decimal.RequireFromString("0.0015")},
+			field:   "size",
+		},
+		{
+			name:    "valid small limit",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.RequireFromString("0.001")},
+			valid:   true,
+		},
+		{
+			name:    "min provide size",
+			payload: models.PlaceOrderPayload{Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.RequireFromString("0.001"), PostOnly: &postOnly},
+			field:   "size",
+		},
+		{
+			name:    "post only mode",
+			payload: models.PlaceOrderPayload{Market: "NEW/USD", Type: models.LimitOrder, Price: decimal.NewFromInt(1), Size: decimal.NewFromInt(1)},
+		},
+		{
+			name:    "valid post only mode",
+			payload: models.PlaceOrderPayload{Market: "NEW/USD", Type: models.LimitOrder, Price: decimal.RequireFromString("1.05"), Size: decimal.NewFromInt(1), PostOnly: &postOnly},
+			valid:   true,
+		},
+		{
+			name:    "disabled",
+			payload: models.PlaceOrderPayload{Market: "OLD/USD", Type: models.MarketOrder, Size: decimal.NewFromInt(1)},
+		},
+		{
+			name:    "unknown",
+			payload: models.PlaceOrderPayload{Market: "FOO/USD", Type: models.MarketOrder, Size: decimal.NewFromInt(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt64(&placed)
+			_, err := client.Orders.PlaceOrder(&tt.payload)
+			if tt.valid {
+				require.NoError(t, err)
+				require.Equal(t, before+1, atomic.LoadInt64(&placed))
+				return
+			}
+
+			var validationErr *OrderValidationError
+			require.True(t, errors.As(err, &validationErr), "%v", err)
+			require.Equal(t, tt.payload.Market, validationErr.Market)
+			require.Equal(t, tt.field, validationErr.Field)
+			require.Equal(t, before, atomic.LoadInt64(&placed))
+		})
+	}
+}
+
+func TestOrderValidator_PlaceTriggerOrder(t *testing.T) {
+	var placed int64
+	client := newValidatorTestAPI(t, &placed)
+
+	// a stop beyond the current bands is fine, the bands are checked once it triggers
+	price := decimal.NewFromInt(10500)
+	_, err := client.Orders.PlaceTriggerOrder(&models.PlaceTriggerOrderPayload{
+		Market: "BTC-PERP", Side: models.Buy, Type: models.Stop, Size: decimal.NewFromInt(1), TriggerPrice: &price,
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, atomic.LoadInt64(&placed))
+
+	price = decimal.RequireFromString("10500.5")
+	_, err = client.Orders.PlaceTriggerOrder(&models.PlaceTriggerOrderPayload{
+		Market: "BTC-PERP", Side: models.Buy, Type: models.Stop, Size: decimal.NewFromInt(1), TriggerPrice: &price,
+	})
+	var validationErr *OrderValidationError
+	require.True(t, errors.As(err, &validationErr), "%v", err)
+	require.Equal(t, "triggerPrice", validationErr.Field)
+	require.EqualValues(t, 1, atomic.LoadInt64(&placed))
+}
+
+func TestOrderValidator_ModifyOrder(t *testing.T) {
+	var placed int64
+	client := newValidatorTestAPI(t, &placed)
+
+	order, err := client.Orders.PlaceOrder(&models.PlaceOrderPayload{
+		Market: "BTC-PERP", Type: models.LimitOrder, Price: decimal.NewFromInt(9000), Size: decimal.NewFromInt(1),
+	})
+	require.NoError(t, err)
+
+	// the market of the placed order is remembered, no GET /orders/5 is needed
+	price := decimal.RequireFromString("9000.5")
+	_, err = client.Orders.ModifyOrder(&models.ModifyOrderPayload{Price: &price}, order.ID)
+	var validationErr *OrderValidationError
+	require.True(t, errors.As(err, &validationErr), "%v", err)
+	require.Equal(t, "price", validationErr.Field)
+
+	price = decimal.NewFromInt(9001)
+	_, err = client.Orders.ModifyOrder(&models.ModifyOrderPayload{Price: &price}, order.ID)
+	require.NoError(t, err)
+}
+
+func TestOrderValidator_Round(t *testing.T) {
+	var placed int64
+	client := newValidatorTestAPI(t, &placed)
+	validator := client.OrderValidator()
+
+	price, err := validator.RoundPrice("NEW/USD", models.Buy, decimal.RequireFromString("1.07"))
+	require.NoError(t, err)
+	require.Equal(t, "1.05", price.String())
+
+	price, err = validator.RoundPrice("NEW/USD", models.Sell, decimal.RequireFromString("1.07"))
+	require.NoError(t, err)
+	require.Equal(t, "1.1", price.String())
+
+	price, err = validator.RoundPrice("NEW/USD", models.Sell, decimal.RequireFromString("1.05"))
+	require.NoError(t, err)
+	require.Equal(t, "1.05", price.String())
+
+	size, err := validator.FloorSize("BTC-PERP", decimal.RequireFromString("0.0129"))
+	require.NoError(t, err)
+	require.Equal(t, "0.012", size.String())
+
+	_, err = validator.FloorSize("FOO/USD", decimal.NewFromInt(1))
+	require.Error(t, err)
+}
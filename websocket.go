@@ -1,6 +1,7 @@
 package goftx
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -9,11 +10,13 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 
 	"github.com/grishinsana/goftx/models"
 )
@@ -25,6 +28,8 @@ const (
 	reconnectCount    = int(10)
 	reconnectInterval = time.Second
 	streamTimeout     = time.Second * 60
+	closeWait         = time.Second
+	pingInterval      = time.Second * 15
 )
 
 type Stream struct {
@@ -34,11 +39,23 @@ type Stream struct {
 	mu                     *sync.Mutex
 	url                    string
 	dialer                 *websocket.Dialer
+	header                 http.Header
 	wsReconnectionCount    int
 	wsReconnectionInterval time.Duration
 	wsTimeout              time.Duration
 	isDebugMode            bool
 	serverTimeDiff         time.Duration
+	pingInterval           time.Duration
+	staleTimeout           time.Duration
+	pingRTT                time.Duration
+	lastPong               time.Time
+	pongHandler            func(rtt time.Duration)
+	reconnectHandlers      map[int]func(channel models.Channel)
+	nextHandlerID          int
+	recorder               *Recorder
+	channels               *channelRegistry
+	lifecycle              *lifecycle
+	metrics                StreamMetrics
 }
 
 func (s *Stream) SetStreamTimeout(timeout time.Duration) {
@@ -69,6 +86,96 @@ func (s *Stream) SetReconnectionInterval(interval time.Duration) {
 	s.wsReconnectionInterval = interval
 }
 
+// SetPingInterval sets how often {"op":"ping"} heartbeats are sent on every connection. Zero disables the heartbeats.
+func (s *Stream) SetPingInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pingInterval = interval
+}
+
+// SetStaleTimeout enables stale feed detection: a market subscription that receives
+// no data for longer than timeout is resubscribed. Zero disables the detection.
+func (s *Stream) SetStaleTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.staleTimeout = timeout
+}
+
+// SetPongHandler sets a callback that receives the round-trip time of every answered ping.
+func (s *Stream) SetPongHandler(handler func(rtt time.Duration)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pongHandler = handler
+}
+
+// AddReconnectHandler registers a callback that is called after a connection serving channel
+// has reconnected and resubscribed. It is called from the read loop and must not block.
+// The returned function removes the handler.
+func (s *Stream) AddReconnectHandler(handler func(channel models.Channel)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reconnectHandlers == nil {
+		s.reconnectHandlers = make(map[int]func(channel models.Channel))
+	}
+	id := s.nextHandlerID
+	s.nextHandlerID++
+	s.reconnectHandlers[id] = handler
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.reconnectHandlers, id)
+	}
+}
+
+// PingRTT returns the last measured ping round-trip time.
+func (s *Stream) PingRTT() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pingRTT
+}
+
+// LastPong returns when the last ping of any connection was answered, zero before the first one.
+func (s *Stream) LastPong() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastPong
+}
+
+// SetRecorder tees every received frame to recorder, nil stops recording.
+func (s *Stream) SetRecorder(recorder *Recorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recorder = recorder
+}
+
+// record tees a frame to the recorder, subAccount is set for connections of private channels.
+func (s *Stream) record(data []byte, subAccount string) {
+	s.mu.Lock()
+	recorder := s.recorder
+	s.mu.Unlock()
+
+	if recorder == nil {
+		return
+	}
+	err := recorder.write(RecordedMessage{
+		Time:       time.Now(),
+		SubAccount: subAccount,
+		Data:       data,
+	})
+	if err != nil {
+		s.printf("record msg: %v", err)
+	}
+}
+
 func (s *Stream) printf(format string, v ...interface{}) {
 	if !s.isDebugMode {
 		return
@@ -80,8 +187,8 @@ func (s *Stream) printf(format string, v ...interface{}) {
 	}
 }
 
-func (s *Stream) connect(requests ...models.WSRequest) (*websocket.Conn, error) {
-	conn, _, err := s.dialer.Dial(s.url, nil)
+func (s *Stream) connect(ctx context.Context, requests ...models.WSRequest) (*websocket.Conn, error) {
+	conn, _, err := s.dialer.DialContext(ctx, s.url, s.header)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -90,6 +197,7 @@ func (s *Stream) connect(requests ...models.WSRequest) (*websocket.Conn, error)
 
 	err = s.subscribe(conn, requests)
 	if err != nil {
+		_ = conn.Close()
 		return nil, errors.WithStack(err)
 	}
 
@@ -102,105 +210,226 @@ func (s *Stream) connect(requests ...models.WSRequest) (*websocket.Conn, error)
 	return conn, nil
 }
 
-func (s *Stream) serve(ctx context.Context, requests ...models.WSRequest) (chan interface{}, error) {
-	conn, err := s.connect(requests...)
+// serve runs a connection for requests. The returned context is done once the subscription
+// is cancelled by the caller, closed by Close or stopped by a failed reconnect.
+func (s *Stream) serve(ctx context.Context, requests ...models.WSRequest) (context.Context, chan event, error) {
+	ctx, _, eventsC, err := s.serveConn(ctx, requests)
+	return ctx, eventsC, err
+}
+
+// serveConn is serve that also returns the connection, so requests could be added to and removed from it.
+func (s *Stream) serveConn(ctx context.Context, requests []models.WSRequest) (context.Context, *connection, chan event, error) {
+	ctx, release, err := s.lifecycle.start(ctx)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, nil, nil, errors.WithStack(err)
+	}
+
+	ws, err := s.connect(ctx, requests...)
+	if err != nil {
+		release()
+		return nil, nil, nil, errors.WithStack(err)
 	}
+	conn := newConnection(ws, requests)
 
 	ftxChannel := models.Channel("")
+	subAccount := s.subAccount
+	recordSubAccount := ""
 	if len(requests) > 0 {
 		ftxChannel = requests[0].Channel
+		subAccount = s.requestSubAccount(requests[0])
+		if s.channels.isPrivate(requests[0]) {
+			recordSubAccount = subAccount
+		}
 	}
 
+	s.mu.Lock()
+	pingInterval, staleTimeout := s.pingInterval, s.staleTimeout
+	s.mu.Unlock()
+
+	metrics := s.streamMetrics()
 	doneC := make(chan struct{})
-	eventsC := make(chan interface{}, 1)
+	eventsC := make(chan event, 1)
 
 	go func() {
+		defer release()
+
 		go func() {
 			defer close(doneC)
 			defer close(eventsC)
 
+			var buf bytes.Buffer
 			for {
-				message := &models.WsResponse{}
-				err = conn.ReadJSON(&message)
+				ws := conn.get()
+				_ = ws.SetReadDeadline(time.Now().Add(s.wsTimeout))
+
+				data, err := readFrame(ws, &buf)
 				if err != nil {
 					s.printf("channel %v read msg: %v", ftxChannel, err)
-					if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					if websocket.IsCloseError(err, websocket.CloseNormalClosure) || ctx.Err() != nil {
 						return
 					}
-					conn, err = s.reconnect(ctx, requests)
+					ws, err = s.reconnect(ctx, conn.subscriptions())
 					if err != nil {
 						s.printf("channel %v reconnect: %+v", ftxChannel, err)
 						return
 					}
+					conn.reset(ws)
+					if ctx.Err() != nil {
+						_ = ws.Close()
+						return
+					}
+					s.handleReconnect(ftxChannel)
 					continue
 				}
+				receivedAt := time.Now()
+				s.record(data, recordSubAccount)
 
-				switch message.Type {
-				case models.Subscribed, models.UnSubscribed:
+				header, response, err := s.channels.decodeFrame(data)
+				if errors.Is(err, errUnknownChannel) {
+					s.printf("channel %v unknown resp channel: %v", ftxChannel, header.Channel)
+					metrics.DecodeError(header.Channel)
 					continue
 				}
-
-				var response interface{}
-				switch message.Channel {
-				case models.TickerChannel:
-					response, err = message.MapToTickerResponse()
-				case models.TradesChannel:
-					response, err = message.MapToTradesResponse()
-				case models.OrderBookChannel:
-					response, err = message.MapToOrderBookResponse()
-				case models.OrdersChannel:
-					response, err = message.MapToOrderResponse()
-				case models.FillsChannel:
-					response, err = message.MapToFillResponse()
-				case models.MarketsChannel:
-					response = message.Data
-				default:
-					s.printf("channel %v unknown resp channel: %v", ftxChannel, message.Channel)
+				if err != nil {
+					s.printf("channel %v map response err: %v", ftxChannel, err)
+					metrics.DecodeError(ftxChannel)
 					continue
 				}
 
-				if err != nil {
-					s.printf("channel %v map response err: %v", ftxChannel, err)
+				switch header.Type {
+				case models.Partial, models.Update:
+				case models.Pong:
+					if rtt, ok := conn.pong(); ok {
+						s.handlePong(rtt)
+					}
+					continue
+				case models.Error, models.Info:
+					s.printf("channel %v %v: %v %v", ftxChannel, header.Type, header.Code, header.Message)
+					continue
+				default:
 					continue
 				}
 
-				eventsC <- response
+				conn.touch(header.Channel, header.Market)
+				response.channel = header.Channel
+				response.market = header.Market
+				response.tagSubAccount(subAccount)
+				metrics.MessageReceived(header.Channel, header.Market, receivedAt)
+				observeLatency(metrics, header, response, receivedAt.Add(s.serverTimeDiff))
+
+				select {
+				case eventsC <- response:
+				case <-ctx.Done():
+					// drain messages that arrive until the close frame is answered
+					metrics.MessageDropped(header.Channel, header.Market)
+				}
 			}
 		}()
 
+		var pingC <-chan time.Time
+		if pingInterval > 0 {
+			pingTicker := time.NewTicker(pingInterval)
+			defer pingTicker.Stop()
+			pingC = pingTicker.C
+		}
+
+		var staleC <-chan time.Time
+		if staleTimeout > 0 {
+			staleTicker := time.NewTicker(staleTimeout / 2)
+			defer staleTicker.Stop()
+			staleC = staleTicker.C
+		}
+
 		for {
 			select {
 			case <-ctx.Done():
-				err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-				if err != nil {
-					s.printf("write close msg: %v", err)
-					return
-				}
-				select {
-				case <-doneC:
-					return
-				case <-time.After(time.Second):
-					return
-				}
+				s.shutdown(conn, doneC)
+				return
 			case <-doneC:
+				_ = conn.close()
 				return
-			case <-time.After((s.wsTimeout * 9) / 10):
+			case <-pingC:
 				s.printf("PING")
-				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				if err := conn.ping(); err != nil {
 					s.printf("write ping: %v", err)
 				}
+			case <-staleC:
+				for _, req := range conn.stale(staleTimeout) {
+					s.printf("channel %v market %v is stale, resubscribe", req.Channel, req.Market)
+					if err := conn.resubscribe(req); err != nil {
+						s.printf("resubscribe: %v", err)
+					}
+				}
 			}
 		}
 	}()
 
-	return eventsC, nil
+	return ctx, conn, eventsC, nil
+}
+
+// shutdown tears a connection down: unsubscribe, close frame, drain until the server
+// answers the close frame or closeWait passes, then close the socket and wait for the read loop.
+func (s *Stream) shutdown(conn *connection, doneC chan struct{}) {
+	defer func() {
+		_ = conn.close()
+		<-doneC
+	}()
+
+	if err := conn.unsubscribe(); err != nil {
+		s.printf("write unsubscribe: %v", err)
+		return
+	}
+	if err := conn.writeClose(); err != nil {
+		s.printf("write close msg: %v", err)
+		return
+	}
+
+	closeTimer := time.NewTimer(closeWait)
+	defer closeTimer.Stop()
+
+	select {
+	case <-doneC:
+	case <-closeTimer.C:
+	}
+}
+
+// requestSubAccount returns the subaccount a private channel request logs in with.
+func (s *Stream) requestSubAccount(req models.WSRequest) string {
+	if req.SubAccount != "" {
+		return req.SubAccount
+	}
+	return s.subAccount
+}
+
+func (s *Stream) handleReconnect(channel models.Channel) {
+	s.mu.Lock()
+	handlers := make([]func(channel models.Channel), 0, len(s.reconnectHandlers))
+	for _, handler := range s.reconnectHandlers {
+		handlers = append(handlers, handler)
+	}
+	s.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(channel)
+	}
+}
+
+func (s *Stream) handlePong(rtt time.Duration) {
+	s.mu.Lock()
+	s.pingRTT = rtt
+	s.lastPong = time.Now()
+	handler := s.pongHandler
+	s.mu.Unlock()
+
+	s.printf("PONG %v", rtt)
+	if handler != nil {
+		handler(rtt)
+	}
 }
 
 // Credit to https://github.com/go-numb/go-ftx
 // nolint:errcheck
-func (s *Stream) auth(conn *websocket.Conn) error {
+func (s *Stream) auth(conn *websocket.Conn, subAccount string) error {
 	if s.apiKey == "" {
 		return errors.New("credentials is required")
 	}
@@ -215,8 +444,8 @@ func (s *Stream) auth(conn *websocket.Conn) error {
 		"sign": hex.EncodeToString(mac.Sum(nil)),
 		"time": msec,
 	}
-	if s.subAccount != "" {
-		args["subaccount"] = s.subAccount
+	if subAccount != "" {
+		args["subaccount"] = subAccount
 	}
 
 	return conn.WriteJSON(models.WSRequest{
@@ -227,24 +456,28 @@ func (s *Stream) auth(conn *websocket.Conn) error {
 
 func (s *Stream) reconnect(ctx context.Context, requests []models.WSRequest) (*websocket.Conn, error) {
 	started := time.Now()
+	metrics := s.streamMetrics()
+	channel := models.Channel("")
+	if len(requests) > 0 {
+		channel = requests[0].Channel
+	}
 
+	// every attempt is followed by an exponential backoff before the next one
 	for i := 1; i < s.wsReconnectionCount; i++ {
-		conn, err := s.connect(requests...)
+		metrics.ReconnectAttempt(channel)
+		conn, err := s.connect(ctx, requests...)
 		if err == nil {
+			metrics.ReconnectSuccess(channel)
 			return conn, nil
 		}
 
-		timeout := time.Duration(int64(math.Pow(2, float64(i)))) * time.Second
+		timeout := time.Duration(int64(math.Pow(2, float64(i)))) * s.wsReconnectionInterval
+		timer := time.NewTimer(timeout)
 
 		select {
-		case <-time.After(timeout):
-			conn, err := s.connect(requests...)
-			if err != nil {
-				continue
-			}
-
-			return conn, nil
+		case <-timer.C:
 		case <-ctx.Done():
+			timer.Stop()
 			return nil, ctx.Err()
 		}
 	}
@@ -255,8 +488,8 @@ func (s *Stream) reconnect(ctx context.Context, requests []models.WSRequest) (*w
 func (s *Stream) subscribe(conn *websocket.Conn, requests []models.WSRequest) error {
 	authorized := false
 	for _, req := range requests {
-		if req.IsPrivateChannel() && !authorized {
-			err := s.auth(conn)
+		if s.channels.isPrivate(req) && !authorized {
+			err := s.auth(conn, s.requestSubAccount(req))
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -271,15 +504,216 @@ func (s *Stream) subscribe(conn *websocket.Conn, requests []models.WSRequest) er
 	return nil
 }
 
-func (s *Stream) SubscribeToFills(ctx context.Context) (chan *models.FillResponse, error) {
-	eventsC, err := s.serve(ctx, models.WSRequest{
+// SubscribeToFills subscribes to fills of the client subaccount or of every given subaccount.
+// Each subaccount is served by its own logged in connection, fills are tagged with their subaccount.
+func (s *Stream) SubscribeToFills(ctx context.Context, subAccounts ...string) (chan *models.FillResponse, error) {
+	ctx, eventsC, err := s.serveSubAccounts(ctx, models.WSRequest{
 		Channel: models.FillsChannel,
 		Op:      models.Subscribe,
+	}, subAccounts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardFills(ctx, eventsC), nil
+}
+
+// SubscribeToOrders subscribes to orders of the client subaccount or of every given subaccount.
+// Each subaccount is served by its own logged in connection, orders are tagged with their subaccount.
+func (s *Stream) SubscribeToOrders(ctx context.Context, subAccounts ...string) (chan *models.OrderResponse, error) {
+	ctx, eventsC, err := s.serveSubAccounts(ctx, models.WSRequest{
+		Channel: models.OrdersChannel,
+		Op:      models.Subscribe,
+	}, subAccounts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardOrders(ctx, eventsC), nil
+}
+
+func (s *Stream) serveSubAccounts(ctx context.Context, request models.WSRequest, subAccounts []string) (context.Context, chan event, error) {
+	if len(subAccounts) == 0 {
+		return s.serve(ctx, request)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	chans := make([]chan event, 0, len(subAccounts))
+	for _, subAccount := range subAccounts {
+		req := request
+		req.SubAccount = subAccount
+		_, eventsC, err := s.serve(ctx, req)
+		if err != nil {
+			cancel()
+			return nil, nil, errors.Wrapf(err, "subaccount %v", subAccount)
+		}
+		chans = append(chans, eventsC)
+	}
+
+	return ctx, mergeEvents(ctx, cancel, chans), nil
+}
+
+// nolint: dupl
+func (s *Stream) SubscribeToTickers(ctx context.Context, symbols ...string) (chan *models.TickerResponse, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("symbols is missing")
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: models.TickerChannel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+		})
+	}
+
+	ctx, eventsC, err := s.serve(ctx, requests...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTickers(ctx, eventsC), nil
+}
+
+func (s *Stream) SubscribeToMarkets(ctx context.Context) (chan *models.Market, error) {
+	ctx, eventsC, err := s.serve(ctx, models.WSRequest{
+		Channel: models.MarketsChannel,
+		Op:      models.Subscribe,
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
+	return forwardMarkets(ctx, eventsC), nil
+}
+
+func (s *Stream) SubscribeToTrades(ctx context.Context, symbols ...string) (chan *models.TradeResponse, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("symbols is missing")
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: models.TradesChannel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+		})
+	}
+
+	ctx, eventsC, err := s.serve(ctx, requests...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTrades(ctx, eventsC), nil
+}
+
+// SubscribeToTradeBatches delivers trades messages as they were sent, one batch of trades per message.
+// nolint: dupl
+func (s *Stream) SubscribeToTradeBatches(ctx context.Context, symbols ...string) (chan *models.TradesResponse, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("symbols is missing")
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: models.TradesChannel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+		})
+	}
+
+	ctx, eventsC, err := s.serve(ctx, requests...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardTradeBatches(ctx, eventsC), nil
+}
+
+// nolint: dupl
+func (s *Stream) SubscribeToOrderBooks(ctx context.Context, symbols ...string) (chan *models.OrderBookResponse, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("symbols is missing")
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel: models.OrderBookChannel,
+			Market:  symbol,
+			Op:      models.Subscribe,
+		})
+	}
+
+	ctx, eventsC, err := s.serve(ctx, requests...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return forwardOrderBooks(ctx, eventsC), nil
+}
+
+// SubscribeToGroupedOrderBooks subscribes to orderbooks aggregated by the grouping price step.
+// Responses carry the same partial and update semantics as SubscribeToOrderBooks and could be merged with OrderBook.Apply.
+func (s *Stream) SubscribeToGroupedOrderBooks(ctx context.Context, grouping decimal.Decimal, symbols ...string) (chan *models.GroupedOrderBookResponse, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("symbols is missing")
+	}
+	if !grouping.IsPositive() {
+		return nil, errors.New("grouping must be positive")
+	}
+
+	requests := make([]models.WSRequest, 0, len(symbols))
+	for _, symbol := range symbols {
+		requests = append(requests, models.WSRequest{
+			Channel:  models.GroupedOrderBookChannel,
+			Market:   symbol,
+			Op:       models.Subscribe,
+			Grouping: json.Number(grouping.String()),
+		})
+	}
+
+	ctx, eventsC, err := s.serve(ctx, requests...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	booksC := make(chan *models.GroupedOrderBookResponse, 1)
+	go func() {
+		defer close(booksC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				book := ev.grouped
+				if book == nil {
+					continue
+				}
+				if book.Grouping.IsZero() {
+					book.Grouping = grouping
+				}
+				select {
+				case booksC <- book:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return booksC, nil
+}
+
+func forwardFills(ctx context.Context, eventsC chan event) chan *models.FillResponse {
 	fillsC := make(chan *models.FillResponse, 1)
 	go func() {
 		defer close(fillsC)
@@ -287,76 +721,75 @@ func (s *Stream) SubscribeToFills(ctx context.Context) (chan *models.FillRespons
 			select {
 			case <-ctx.Done():
 				return
-			case event, ok := <-eventsC:
+			case ev, ok := <-eventsC:
 				if !ok {
 					return
 				}
-				fill, ok := event.(*models.FillResponse)
-				if !ok {
+				fill := ev.fill
+				if fill == nil {
+					continue
+				}
+				select {
+				case fillsC <- fill:
+				case <-ctx.Done():
 					return
 				}
-				fillsC <- fill
 			}
 		}
 	}()
 
-	return fillsC, nil
+	return fillsC
 }
 
-func (s *Stream) SubscribeToOrders(ctx context.Context) (chan *models.OrderResponse, error) {
-	eventsC, err := s.serve(ctx, models.WSRequest{
-		Channel: models.OrdersChannel,
-		Op:      models.Subscribe,
-	})
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
+// forwardOrders fills the delta of every order against the previous message of the order.
+// Closed orders are forgotten, so only open orders are kept in memory.
+func forwardOrders(ctx context.Context, eventsC chan event) chan *models.OrderResponse {
 	ordersC := make(chan *models.OrderResponse, 1)
 	go func() {
 		defer close(ordersC)
+		type orderKey struct {
+			subAccount string
+			id         int64
+		}
+		previous := make(map[orderKey]models.Order)
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case event, ok := <-eventsC:
+			case ev, ok := <-eventsC:
 				if !ok {
-					s.printf("read from chan not ok")
 					return
 				}
-				order, ok := event.(*models.OrderResponse)
-				if !ok {
-					s.printf("resp is not order response: %T", event)
+				order := ev.order
+				if order == nil {
+					continue
+				}
+
+				key := orderKey{subAccount: order.SubAccount, id: order.ID}
+				if last, ok := previous[key]; ok {
+					order.Delta = models.NewOrderDelta(&last, order.Order)
+				} else {
+					order.Delta = models.NewOrderDelta(nil, order.Order)
+				}
+				if order.Status == models.Closed {
+					delete(previous, key)
+				} else {
+					previous[key] = order.Order
+				}
+
+				select {
+				case ordersC <- order:
+				case <-ctx.Done():
 					return
 				}
-				ordersC <- order
 			}
 		}
 	}()
 
-	return ordersC, nil
+	return ordersC
 }
 
-// nolint: dupl
-func (s *Stream) SubscribeToTickers(ctx context.Context, symbols ...string) (chan *models.TickerResponse, error) {
-	if len(symbols) == 0 {
-		return nil, errors.New("symbols is missing")
-	}
-
-	requests := make([]models.WSRequest, 0, len(symbols))
-	for _, symbol := range symbols {
-		requests = append(requests, models.WSRequest{
-			Channel: models.TickerChannel,
-			Market:  symbol,
-			Op:      models.Subscribe,
-		})
-	}
-
-	eventsC, err := s.serve(ctx, requests...)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
+func forwardTickers(ctx context.Context, eventsC chan event) chan *models.TickerResponse {
 	tickersC := make(chan *models.TickerResponse, 1)
 	go func() {
 		defer close(tickersC)
@@ -364,31 +797,27 @@ func (s *Stream) SubscribeToTickers(ctx context.Context, symbols ...string) (cha
 			select {
 			case <-ctx.Done():
 				return
-			case event, ok := <-eventsC:
+			case ev, ok := <-eventsC:
 				if !ok {
 					return
 				}
-				ticker, ok := event.(*models.TickerResponse)
-				if !ok {
+				ticker := ev.ticker
+				if ticker == nil {
+					continue
+				}
+				select {
+				case tickersC <- ticker:
+				case <-ctx.Done():
 					return
 				}
-				tickersC <- ticker
 			}
 		}
 	}()
 
-	return tickersC, nil
+	return tickersC
 }
 
-func (s *Stream) SubscribeToMarkets(ctx context.Context) (chan *models.Market, error) {
-	eventsC, err := s.serve(ctx, models.WSRequest{
-		Channel: models.MarketsChannel,
-		Op:      models.Subscribe,
-	})
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
+func forwardMarkets(ctx context.Context, eventsC chan event) chan *models.Market {
 	marketsC := make(chan *models.Market, 1)
 	go func() {
 		defer close(marketsC)
@@ -396,51 +825,29 @@ func (s *Stream) SubscribeToMarkets(ctx context.Context) (chan *models.Market, e
 			select {
 			case <-ctx.Done():
 				return
-			case event, ok := <-eventsC:
-				if !ok {
-					return
-				}
-				data, ok := event.(json.RawMessage)
+			case ev, ok := <-eventsC:
 				if !ok {
 					return
 				}
-				var markets struct {
-					Data map[string]*models.Market `json:"data"`
-				}
-				err = json.Unmarshal(data, &markets)
-				if err != nil {
-					s.printf("unmarshal markets: %+v", err)
-					return
+				markets := ev.markets
+				if markets == nil {
+					continue
 				}
-				for _, market := range markets.Data {
-					marketsC <- market
+				for _, market := range markets.Markets {
+					select {
+					case marketsC <- market:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
 		}
 	}()
 
-	return marketsC, nil
+	return marketsC
 }
 
-func (s *Stream) SubscribeToTrades(ctx context.Context, symbols ...string) (chan *models.TradeResponse, error) {
-	if len(symbols) == 0 {
-		return nil, errors.New("symbols is missing")
-	}
-
-	requests := make([]models.WSRequest, 0, len(symbols))
-	for _, symbol := range symbols {
-		requests = append(requests, models.WSRequest{
-			Channel: models.TradesChannel,
-			Market:  symbol,
-			Op:      models.Subscribe,
-		})
-	}
-
-	eventsC, err := s.serve(ctx, requests...)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
+func forwardTrades(ctx context.Context, eventsC chan event) chan *models.TradeResponse {
 	tradesC := make(chan *models.TradeResponse, 1)
 	go func() {
 		defer close(tradesC)
@@ -448,47 +855,62 @@ func (s *Stream) SubscribeToTrades(ctx context.Context, symbols ...string) (chan
 			select {
 			case <-ctx.Done():
 				return
-			case event, ok := <-eventsC:
+			case ev, ok := <-eventsC:
 				if !ok {
 					return
 				}
-				trades, ok := event.(*models.TradesResponse)
-				if !ok {
-					return
+				trades := ev.trades
+				if trades == nil {
+					continue
 				}
-				for _, trade := range trades.Trades {
-					tradesC <- &models.TradeResponse{
+				for i, trade := range trades.Trades {
+					select {
+					case tradesC <- &models.TradeResponse{
 						Trade:        trade,
+						BatchIndex:   i,
+						BatchSize:    len(trades.Trades),
 						BaseResponse: trades.BaseResponse,
+					}:
+					case <-ctx.Done():
+						return
 					}
 				}
 			}
 		}
 	}()
 
-	return tradesC, nil
+	return tradesC
 }
 
-// nolint: dupl
-func (s *Stream) SubscribeToOrderBooks(ctx context.Context, symbols ...string) (chan *models.OrderBookResponse, error) {
-	if len(symbols) == 0 {
-		return nil, errors.New("symbols is missing")
-	}
-
-	requests := make([]models.WSRequest, 0, len(symbols))
-	for _, symbol := range symbols {
-		requests = append(requests, models.WSRequest{
-			Channel: models.OrderBookChannel,
-			Market:  symbol,
-			Op:      models.Subscribe,
-		})
-	}
+func forwardTradeBatches(ctx context.Context, eventsC chan event) chan *models.TradesResponse {
+	batchesC := make(chan *models.TradesResponse, 1)
+	go func() {
+		defer close(batchesC)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-eventsC:
+				if !ok {
+					return
+				}
+				trades := ev.trades
+				if trades == nil {
+					continue
+				}
+				select {
+				case batchesC <- trades:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
 
-	eventsC, err := s.serve(ctx, requests...)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
+	return batchesC
+}
 
+func forwardOrderBooks(ctx context.Context, eventsC chan event) chan *models.OrderBookResponse {
 	booksC := make(chan *models.OrderBookResponse, 1)
 	go func() {
 		defer close(booksC)
@@ -496,18 +918,22 @@ func (s *Stream) SubscribeToOrderBooks(ctx context.Context, symbols ...string) (
 			select {
 			case <-ctx.Done():
 				return
-			case event, ok := <-eventsC:
+			case ev, ok := <-eventsC:
 				if !ok {
 					return
 				}
-				book, ok := event.(*models.OrderBookResponse)
-				if !ok {
+				book := ev.book
+				if book == nil {
+					continue
+				}
+				select {
+				case booksC <- book:
+				case <-ctx.Done():
 					return
 				}
-				booksC <- book
 			}
 		}
 	}()
 
-	return booksC, nil
+	return booksC
 }
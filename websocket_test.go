@@ -2,9 +2,13 @@ package goftx
 
 import (
 	"context"
+	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grishinsana/goftx/models"
@@ -130,3 +134,218 @@ func TestStream_SubscribeToOrderBooks(t *testing.T) {
 	}
 	require.True(t, count > 0)
 }
+
+func TestStream_SubscribeToFills_SubAccounts(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		logins = map[*websocket.Conn]string{}
+	)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		switch req.Op {
+		case models.Login:
+			mu.Lock()
+			logins[conn], _ = req.Args["subaccount"].(string)
+			mu.Unlock()
+		case models.Subscribe:
+			mu.Lock()
+			subAccount := logins[conn]
+			mu.Unlock()
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": models.FillsChannel,
+				"type":    models.Update,
+				"data":    map[string]interface{}{"id": len(subAccount), "market": "BTC-PERP", "price": 1, "size": 1},
+			})
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subAccounts := []string{"alpha", "beta-1"}
+	data, err := ftx.Stream.SubscribeToFills(ctx, subAccounts...)
+	require.NoError(t, err)
+
+	received := map[string]int64{}
+	for len(received) < len(subAccounts) {
+		select {
+		case msg := <-data:
+			received[msg.SubAccount] = msg.ID
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received only %v", received)
+		}
+	}
+	require.Equal(t, map[string]int64{"alpha": 5, "beta-1": 6}, received)
+}
+
+func TestStream_SubscribeToGroupedOrderBooks(t *testing.T) {
+	groupingC := make(chan json.Number, 1)
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		groupingC <- req.Grouping
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Partial,
+			"data": map[string]interface{}{
+				"bids": [][]float64{{9000, 1}, {8500, 2}},
+				"asks": [][]float64{{9500, 3}, {10000, 4}},
+			},
+		})
+		_ = conn.WriteJSON(map[string]interface{}{
+			"channel": req.Channel,
+			"market":  req.Market,
+			"type":    models.Update,
+			"data": map[string]interface{}{
+				"bids": [][]float64{{9000, 0}, {8000, 5}},
+				"asks": [][]float64{{9500, 1}, {9000.5, 2}},
+			},
+		})
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data, err := ftx.Stream.SubscribeToGroupedOrderBooks(ctx, decimal.NewFromInt(500), "BTC-PERP")
+	require.NoError(t, err)
+	require.Equal(t, json.Number("500"), <-groupingC)
+
+	book := models.OrderBook{}
+	for _, expected := range []models.ResponseType{models.Partial, models.Update} {
+		select {
+		case msg := <-data:
+			require.Equal(t, expected, msg.Type)
+			require.Equal(t, "BTC-PERP", msg.Symbol)
+			require.True(t, msg.Grouping.Equal(decimal.NewFromInt(500)))
+			require.Equal(t, expected == models.Partial, msg.IsSnapshot)
+			book.Apply(msg.Type, msg.OrderBook)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no orderbook received")
+		}
+	}
+
+	levels := func(levels [][]decimal.Decimal) []string {
+		result := make([]string, 0, len(levels))
+		for _, level := range levels {
+			result = append(result, level[0].String()+":"+level[1].String())
+		}
+		return result
+	}
+	require.Equal(t, []string{"8500:2", "8000:5"}, levels(book.Bids))
+	require.Equal(t, []string{"9000.5:2", "9500:1", "10000:4"}, levels(book.Asks))
+}
+
+func TestStream_SubscribeToOrders_Delta(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		for _, order := range []map[string]interface{}{
+			{"id": 1, "status": models.New, "size": 3, "filledSize": 0},
+			{"id": 2, "status": models.Open, "size": 1, "filledSize": 0},
+			{"id": 1, "status": models.Open, "size": 3, "filledSize": 1},
+			{"id": 1, "status": models.Open, "size": 3, "filledSize": 1.5},
+			{"id": 1, "status": models.Closed, "size": 3, "filledSize": 3},
+		} {
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": models.OrdersChannel,
+				"type":    models.Update,
+				"data":    order,
+			})
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts, WithAuth("key", "secret"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data, err := ftx.Stream.SubscribeToOrders(ctx)
+	require.NoError(t, err)
+
+	type delta struct {
+		id             int64
+		first          bool
+		filled         string
+		previousStatus models.Status
+		statusChanged  bool
+	}
+	expected := []delta{
+		{id: 1, first: true, filled: "0", statusChanged: true},
+		{id: 2, first: true, filled: "0", statusChanged: true},
+		{id: 1, filled: "1", previousStatus: models.New, statusChanged: true},
+		{id: 1, filled: "0.5", previousStatus: models.Open},
+		{id: 1, filled: "1.5", previousStatus: models.Open, statusChanged: true},
+	}
+	for _, e := range expected {
+		select {
+		case msg := <-data:
+			require.Equal(t, models.StateEvent, msg.Kind)
+			require.Equal(t, e, delta{
+				id:             msg.ID,
+				first:          msg.Delta.First,
+				filled:         msg.Delta.FilledSize.String(),
+				previousStatus: msg.Delta.PreviousStatus,
+				statusChanged:  msg.Delta.StatusChanged,
+			})
+		case <-time.After(2 * time.Second):
+			t.Fatal("no order received")
+		}
+	}
+}
+
+func TestStream_SubscribeToTradeBatches(t *testing.T) {
+	ts := newTestServer(t, func(conn *websocket.Conn, req models.WSRequest) {
+		if req.Op != models.Subscribe {
+			return
+		}
+		for _, batch := range [][]map[string]interface{}{
+			{{"id": 1, "price": 9000, "size": 1}, {"id": 2, "price": 9001, "size": 2}},
+			{{"id": 3, "price": 9002, "size": 3}},
+		} {
+			_ = conn.WriteJSON(map[string]interface{}{
+				"channel": req.Channel,
+				"market":  req.Market,
+				"type":    models.Update,
+				"data":    batch,
+			})
+		}
+	})
+	defer ts.Close()
+
+	ftx := newTestClient(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches, err := ftx.Stream.SubscribeToTradeBatches(ctx, "BTC-PERP")
+	require.NoError(t, err)
+	for _, size := range []int{2, 1} {
+		select {
+		case msg := <-batches:
+			require.Len(t, msg.Trades, size)
+			require.Equal(t, models.RecordEvent, msg.Kind)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no trades received")
+		}
+	}
+
+	trades, err := ftx.Stream.SubscribeToTrades(ctx, "BTC-PERP")
+	require.NoError(t, err)
+	for _, position := range [][2]int{{0, 2}, {1, 2}, {0, 1}} {
+		select {
+		case msg := <-trades:
+			require.Equal(t, position, [2]int{msg.BatchIndex, msg.BatchSize})
+		case <-time.After(2 * time.Second):
+			t.Fatal("no trade received")
+		}
+	}
+}